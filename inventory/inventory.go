@@ -0,0 +1,452 @@
+// Package inventory resolves hosts & groups declared in ansible inventories
+package inventory
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/meomap/zeno/loader"
+)
+
+const (
+	groupAll       = "all"
+	groupUngrouped = "ungrouped"
+)
+
+// group lists hosts and children groups directly declared
+type group struct {
+	hosts    []string
+	children []string
+}
+
+// Inventory holds groups & hosts parsed from inventory sources
+type Inventory struct {
+	// Dir is where group_vars & host_vars of inventory are located
+	Dir    string
+	groups map[string]*group
+	hosts  map[string]bool
+}
+
+// New returns empty inventory located at dir
+func New(dir string) *Inventory {
+	return &Inventory{
+		Dir:    dir,
+		groups: map[string]*group{groupAll: {}, groupUngrouped: {}},
+		hosts:  map[string]bool{},
+	}
+}
+
+// Parse reads inventory from file in INI or YAML format, or from all files
+// within a directory
+func Parse(filePath string, ds loader.DataSource) (*Inventory, error) {
+	isDir, err := ds.IsDir(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ds.IsDir path=%s", filePath)
+	}
+	if !isDir {
+		inv := New(filepath.Dir(filePath))
+		if err = inv.load(filePath, ds); err != nil {
+			return nil, errors.Wrapf(err, "load path=%s", filePath)
+		}
+		return inv, nil
+	}
+	inv := New(filePath)
+	entries, err := ds.ReadDir(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ds.ReadDir path=%s", filePath)
+	}
+	sort.Strings(entries)
+	for _, entry := range entries {
+		p := path.Join(filePath, entry)
+		if isDir, err = ds.IsDir(p); err != nil {
+			return nil, errors.Wrapf(err, "ds.IsDir path=%s", p)
+		} else if isDir || ignoredSource(entry) {
+			continue
+		}
+		if err = inv.load(p, ds); err != nil {
+			return nil, errors.Wrapf(err, "load path=%s", p)
+		}
+	}
+	return inv, nil
+}
+
+// ignoredSource reports whether file inside inventory dir is skipped, same
+// as ansible default INVENTORY_IGNORE_EXTS
+func ignoredSource(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return true
+	}
+	switch path.Ext(name) {
+	case ".orig", ".ini", ".cfg", ".retry", ".pyc", ".pyo", ".swp", ".bak", ".rpm", ".md", ".txt", ".rst":
+		return true
+	}
+	return false
+}
+
+func (inv *Inventory) load(filePath string, ds loader.DataSource) error {
+	content, err := ds.ReadFile(filePath)
+	if err != nil {
+		return errors.Wrapf(err, "dataSource file_path=%s", filePath)
+	}
+	switch path.Ext(filePath) {
+	case ".yml", ".yaml", ".json":
+		return inv.parseYAML(content)
+	}
+	return inv.parseINI(content)
+}
+
+// parseINI reads sections of `[group]`, `[group:children]` & `[group:vars]`
+func (inv *Inventory) parseINI(content []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	current, kind := groupUngrouped, "hosts"
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return errors.Errorf("malformed section at line %d: %s", lineNo, line)
+			}
+			current, kind = line[1:len(line)-1], "hosts"
+			if idx := strings.Index(current, ":"); idx > 0 {
+				current, kind = current[:idx], current[idx+1:]
+			}
+			inv.addGroup(current)
+			continue
+		}
+		name := strings.Fields(line)[0]
+		switch kind {
+		case "hosts":
+			for _, host := range expandHostRange(stripPort(name)) {
+				inv.addHost(current, host)
+			}
+		case "children":
+			inv.addGroup(name)
+			inv.addChild(current, name)
+		}
+	}
+	return errors.Wrap(scanner.Err(), "scanner.Scan")
+}
+
+// yamlGroup is group definition of YAML inventory
+type yamlGroup struct {
+	Hosts    map[string]interface{} `yaml:"hosts"`
+	Children map[string]*yamlGroup  `yaml:"children"`
+}
+
+func (inv *Inventory) parseYAML(content []byte) error {
+	groups := map[string]*yamlGroup{}
+	if err := yaml.Unmarshal(content, &groups); err != nil {
+		return errors.Wrap(err, "yaml.Unmarshal")
+	}
+	for name, g := range groups {
+		inv.addYAMLGroup(name, g)
+	}
+	return nil
+}
+
+func (inv *Inventory) addYAMLGroup(name string, g *yamlGroup) {
+	inv.addGroup(name)
+	if g == nil {
+		return
+	}
+	for pattern := range g.Hosts {
+		for _, host := range expandHostRange(pattern) {
+			inv.addHost(name, host)
+		}
+	}
+	for child, cg := range g.Children {
+		inv.addYAMLGroup(child, cg)
+		if name != groupAll {
+			inv.addChild(name, child)
+		}
+	}
+}
+
+func (inv *Inventory) addGroup(name string) {
+	if _, ok := inv.groups[name]; !ok {
+		inv.groups[name] = &group{}
+	}
+}
+
+func (inv *Inventory) addHost(groupName string, host string) {
+	inv.addGroup(groupName)
+	g := inv.groups[groupName]
+	for _, v := range g.hosts {
+		if v == host {
+			return
+		}
+	}
+	g.hosts = append(g.hosts, host)
+	inv.hosts[host] = true
+}
+
+func (inv *Inventory) addChild(parent string, child string) {
+	g := inv.groups[parent]
+	for _, v := range g.children {
+		if v == child {
+			return
+		}
+	}
+	g.children = append(g.children, child)
+}
+
+// stripPort removes trailing `:port` of host declared in INI format
+func stripPort(host string) string {
+	idx := strings.LastIndex(host, ":")
+	if idx <= 0 || strings.Count(host, ":") > 1 {
+		return host
+	}
+	if _, err := strconv.Atoi(host[idx+1:]); err != nil {
+		return host
+	}
+	return host[:idx]
+}
+
+var hostRangePattern = regexp.MustCompile(`\[([0-9a-zA-Z]+):([0-9a-zA-Z]+)(?::([0-9]+))?\]`)
+
+// expandHostRange turns `web[01:03]` into web01, web02 & web03
+func expandHostRange(pattern string) []string {
+	loc := hostRangePattern.FindStringSubmatchIndex(pattern)
+	if loc == nil {
+		return []string{pattern}
+	}
+	head, tail := pattern[:loc[0]], pattern[loc[1]:]
+	start, end := pattern[loc[2]:loc[3]], pattern[loc[4]:loc[5]]
+	step := 1
+	if loc[6] >= 0 {
+		var err error
+		if step, err = strconv.Atoi(pattern[loc[6]:loc[7]]); err != nil {
+			// step overflowing int isn't a valid range
+			return []string{pattern}
+		}
+		if step <= 0 {
+			step = 1
+		}
+	}
+	var items []string
+	if from, err := strconv.Atoi(start); err == nil {
+		to, tErr := strconv.Atoi(end)
+		if tErr != nil {
+			return []string{pattern}
+		}
+		width := 0
+		if strings.HasPrefix(start, "0") {
+			width = len(start)
+		}
+		for i := from; i <= to; i += step {
+			v := strconv.Itoa(i)
+			for len(v) < width {
+				v = "0" + v
+			}
+			items = append(items, v)
+			if step > to-i {
+				// next item would overflow past end
+				break
+			}
+		}
+	} else if len(start) == 1 && len(end) == 1 {
+		for c, to := int(start[0]), int(end[0]); c <= to; c += step {
+			items = append(items, string(rune(c)))
+			if step > to-c {
+				break
+			}
+		}
+	} else {
+		return []string{pattern}
+	}
+	var out []string
+	for _, item := range items {
+		// remaining ranges in tail
+		for _, rest := range expandHostRange(tail) {
+			out = append(out, head+item+rest)
+		}
+	}
+	return out
+}
+
+// AllHosts returns sorted names of every host in inventory
+func (inv *Inventory) AllHosts() []string {
+	out := make([]string, 0, len(inv.hosts))
+	for h := range inv.hosts {
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// GroupHosts returns hosts belong to group or any of its descendants
+func (inv *Inventory) GroupHosts(name string) []string {
+	if name == groupAll {
+		return inv.AllHosts()
+	}
+	seen := map[string]bool{}
+	found := map[string]bool{}
+	var walk func(string)
+	walk = func(n string) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		g, ok := inv.groups[n]
+		if !ok {
+			return
+		}
+		for _, h := range g.hosts {
+			found[h] = true
+		}
+		for _, c := range g.children {
+			walk(c)
+		}
+	}
+	walk(name)
+	if name == groupUngrouped {
+		for h := range inv.hosts {
+			if len(inv.groupsOf(h)) == 0 {
+				found[h] = true
+			}
+		}
+	}
+	return sortedKeys(found)
+}
+
+// groupsOf returns groups other than all/ungrouped declaring host directly
+func (inv *Inventory) groupsOf(host string) []string {
+	var out []string
+	for name, g := range inv.groups {
+		if name == groupAll || name == groupUngrouped {
+			continue
+		}
+		for _, h := range g.hosts {
+			if h == host {
+				out = append(out, name)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Hosts resolves ansible host pattern like `web:&prod:!web01` into host names.
+// Pattern that could not be resolved statically targets all hosts
+func (inv *Inventory) Hosts(pattern string) []string {
+	pattern = strings.TrimSpace(pattern)
+	if strings.Contains(pattern, "{{") {
+		return inv.AllHosts()
+	}
+	var terms []string
+	if strings.Contains(pattern, ",") {
+		terms = strings.Split(pattern, ",")
+	} else {
+		terms = strings.Split(pattern, ":")
+	}
+	var (
+		union, intersect, exclude []string
+	)
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		switch {
+		case term == "":
+		case strings.HasPrefix(term, "&"):
+			intersect = append(intersect, term[1:])
+		case strings.HasPrefix(term, "!"):
+			exclude = append(exclude, term[1:])
+		default:
+			union = append(union, term)
+		}
+	}
+	if len(union) == 0 {
+		union = []string{groupAll}
+	}
+	selected := map[string]bool{}
+	for _, term := range union {
+		for _, h := range inv.matchTerm(term) {
+			selected[h] = true
+		}
+	}
+	for _, term := range intersect {
+		matched := map[string]bool{}
+		for _, h := range inv.matchTerm(term) {
+			matched[h] = true
+		}
+		for h := range selected {
+			if !matched[h] {
+				delete(selected, h)
+			}
+		}
+	}
+	for _, term := range exclude {
+		for _, h := range inv.matchTerm(term) {
+			delete(selected, h)
+		}
+	}
+	return sortedKeys(selected)
+}
+
+var subscriptPattern = regexp.MustCompile(`\[[0-9:-]*\]$`)
+
+// matchTerm resolves single term of host pattern
+func (inv *Inventory) matchTerm(term string) []string {
+	// subscripts only narrow hosts of group down, keep them all
+	term = subscriptPattern.ReplaceAllString(term, "")
+	if term == groupAll || term == "*" {
+		return inv.AllHosts()
+	}
+	if _, ok := inv.groups[term]; ok {
+		return inv.GroupHosts(term)
+	}
+	if inv.hosts[term] {
+		return []string{term}
+	}
+	var matcher func(string) bool
+	if strings.HasPrefix(term, "~") {
+		re, err := regexp.Compile(term[1:])
+		if err != nil {
+			return nil
+		}
+		matcher = re.MatchString
+	} else if strings.ContainsAny(term, "*?[") {
+		matcher = func(name string) bool {
+			ok, _ := path.Match(term, name)
+			return ok
+		}
+	} else if term == "localhost" || term == "127.0.0.1" {
+		// implicit localhost
+		return []string{term}
+	} else {
+		return nil
+	}
+	found := map[string]bool{}
+	for name := range inv.groups {
+		if matcher(name) {
+			for _, h := range inv.GroupHosts(name) {
+				found[h] = true
+			}
+		}
+	}
+	for h := range inv.hosts {
+		if matcher(h) {
+			found[h] = true
+		}
+	}
+	return sortedKeys(found)
+}
+
+func sortedKeys(items map[string]bool) []string {
+	out := make([]string, 0, len(items))
+	for k := range items {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,186 @@
+package inventory
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/meomap/zeno/loader"
+)
+
+const iniInventory = `
+# comment
+jumphost
+
+[webservers]
+web[01:03].example.com ansible_user=deploy
+; another comment
+[dbservers]
+db1:5432
+db2
+
+[prod:children]
+webservers
+dbservers
+
+[prod:vars]
+env=prod
+`
+
+const yamlInventory = `
+all:
+  hosts:
+    jumphost:
+  children:
+    webservers:
+      hosts:
+        web[01:02].example.com:
+    prod:
+      children:
+        webservers:
+        dbservers:
+          hosts:
+            db1:
+`
+
+func TestParse(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		input    string
+		setup    func()
+		err      bool
+		dir      string
+		groups   map[string][]string
+	}{
+		{
+			caseName: "ini_format",
+			input:    "inventories/prod/hosts",
+			setup: func() {
+				ds.SetFile("inventories/prod/hosts", []byte(iniInventory))
+			},
+			dir: "inventories/prod",
+			groups: map[string][]string{
+				"webservers": {"web01.example.com", "web02.example.com", "web03.example.com"},
+				"dbservers":  {"db1", "db2"},
+				"prod":       {"db1", "db2", "web01.example.com", "web02.example.com", "web03.example.com"},
+				"ungrouped":  {"jumphost"},
+			},
+		},
+		{
+			caseName: "yaml_format",
+			input:    "inventories/prod/hosts.yml",
+			setup: func() {
+				ds.SetFile("inventories/prod/hosts.yml", []byte(yamlInventory))
+			},
+			dir: "inventories/prod",
+			groups: map[string][]string{
+				"webservers": {"web01.example.com", "web02.example.com"},
+				"dbservers":  {"db1"},
+				"prod":       {"db1", "web01.example.com", "web02.example.com"},
+				"all":        {"db1", "jumphost", "web01.example.com", "web02.example.com"},
+			},
+		},
+		{
+			caseName: "directory_of_sources",
+			input:    "inventories/staging",
+			setup: func() {
+				ds.SetFile("inventories/staging/web", []byte("[webservers]\nweb01"))
+				ds.SetFile("inventories/staging/db.yml", []byte("dbservers:\n  hosts:\n    db01:"))
+				ds.SetFile("inventories/staging/notes.md", []byte("[broken"))
+				ds.SetFile("inventories/staging/group_vars/all.yml", []byte(""))
+			},
+			dir: "inventories/staging",
+			groups: map[string][]string{
+				"webservers": {"web01"},
+				"dbservers":  {"db01"},
+			},
+		},
+		{
+			caseName: "malformed_ini_section",
+			input:    "malformed",
+			setup: func() {
+				ds.SetFile("malformed", []byte("[webservers\nweb01"))
+			},
+			err: true,
+		},
+		{
+			caseName: "malformed_yaml",
+			input:    "malformed.yml",
+			setup: func() {
+				ds.SetFile("malformed.yml", []byte("abcde"))
+			},
+			err: true,
+		},
+		{
+			caseName: "file_not_exist",
+			input:    "not-exist",
+			setup:    func() {},
+			err:      true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			inv, err := Parse(c.input, ds)
+			if c.err == true {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.dir, inv.Dir)
+			for name, hosts := range c.groups {
+				assert.Equal(t, hosts, inv.GroupHosts(name), name)
+			}
+		})
+	}
+}
+
+func TestHosts(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	ds.SetFile("hosts", []byte(iniInventory))
+	inv, err := Parse("hosts", ds)
+	require.NoError(t, err)
+	for _, c := range []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "all", want: []string{"db1", "db2", "jumphost", "web01.example.com", "web02.example.com", "web03.example.com"}},
+		{pattern: "dbservers", want: []string{"db1", "db2"}},
+		{pattern: "web01.example.com", want: []string{"web01.example.com"}},
+		{pattern: "dbservers:webservers[0]", want: []string{"db1", "db2", "web01.example.com", "web02.example.com", "web03.example.com"}},
+		{pattern: "prod:&dbservers", want: []string{"db1", "db2"}},
+		{pattern: "prod:!webservers:!db2", want: []string{"db1"}},
+		{pattern: "dbservers,jumphost", want: []string{"db1", "db2", "jumphost"}},
+		{pattern: "web0*", want: []string{"web01.example.com", "web02.example.com", "web03.example.com"}},
+		{pattern: "~db[12]", want: []string{"db1", "db2"}},
+		{pattern: "!prod", want: []string{"jumphost"}},
+		{pattern: "localhost", want: []string{"localhost"}},
+		{pattern: "unknown", want: []string{}},
+		{pattern: "{{ target }}", want: []string{"db1", "db2", "jumphost", "web01.example.com", "web02.example.com", "web03.example.com"}},
+	} {
+		assert.Equal(t, c.want, inv.Hosts(c.pattern), c.pattern)
+	}
+}
+
+func TestExpandHostRange(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "web", want: []string{"web"}},
+		{pattern: "web[1:3]", want: []string{"web1", "web2", "web3"}},
+		{pattern: "web[08:10].lan", want: []string{"web08.lan", "web09.lan", "web10.lan"}},
+		{pattern: "db-[a:c]", want: []string{"db-a", "db-b", "db-c"}},
+		{pattern: "node[0:4:2]", want: []string{"node0", "node2", "node4"}},
+		{pattern: "r[1:2]-[a:b]", want: []string{"r1-a", "r1-b", "r2-a", "r2-b"}},
+		{pattern: "web[a:c:256]", want: []string{"weba"}},
+		{pattern: "web[a:z:10]", want: []string{"weba", "webk", "webu"}},
+		{pattern: "node[9223372036854775806:9223372036854775807:2]", want: []string{"node9223372036854775806"}},
+		{pattern: "node[0:4:99999999999999999999]", want: []string{"node[0:4:99999999999999999999]"}},
+	} {
+		assert.Equal(t, c.want, expandHostRange(c.pattern), c.pattern)
+	}
+}
@@ -1,8 +1,10 @@
 package loader
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
@@ -15,11 +17,13 @@ type DataSource interface {
 	ReadFile(string) ([]byte, error)
 	ReadDir(string) ([]string, error)
 	IsExist(string) (bool, error)
+	IsDir(string) (bool, error)
 }
 
 // MemoryLoader implements IO operations for testing
 type MemoryLoader struct {
 	files map[string][]byte
+	dirs  map[string]bool
 }
 
 // ReadFile returns byte content given preload file name
@@ -35,6 +39,7 @@ func (ml MemoryLoader) ReadFile(name string) ([]byte, error) {
 func (ml *MemoryLoader) SetFile(name string, content []byte) {
 	if ml.files == nil {
 		ml.files = map[string][]byte{}
+		ml.dirs = map[string]bool{}
 	}
 	pathComps := strings.Split(name, string(filepath.Separator))
 	lenComps := len(pathComps)
@@ -46,22 +51,37 @@ func (ml *MemoryLoader) SetFile(name string, content []byte) {
 	for i := 0; i < lenComps-1; i++ {
 		if i == 0 {
 			parent = pathComps[i]
+			if parent == "" {
+				// absolute path
+				parent = string(filepath.Separator)
+			}
 		} else {
 			parent = path.Join(parent, pathComps[i])
 		}
 		child := pathComps[i+1]
 		if subdir, ok = ml.files[parent]; !ok {
 			subdir = []byte(child)
-		} else {
+		} else if !hasChild(string(subdir), child) {
 			updated := strings.Split(string(subdir), ",")
 			updated = append(updated, child)
 			subdir = []byte(strings.Join(updated, ","))
 		}
 		ml.files[parent] = subdir
+		ml.dirs[parent] = true
 	}
 	ml.files[name] = content
 }
 
+// hasChild reports whether child is already listed in subdir content
+func hasChild(subdir string, child string) bool {
+	for _, v := range strings.Split(subdir, ",") {
+		if v == child {
+			return true
+		}
+	}
+	return false
+}
+
 // ReadDir returns list of files' name under specified directory
 func (ml MemoryLoader) ReadDir(name string) ([]string, error) {
 	content, ok := ml.files[name]
@@ -92,9 +112,18 @@ func (ml MemoryLoader) IsExist(name string) (ok bool, err error) {
 	return
 }
 
+// IsDir returns true if given name has been set as parent of other files
+func (ml MemoryLoader) IsDir(name string) (bool, error) {
+	if _, err := ml.IsExist(name); err != nil {
+		return false, err
+	}
+	return ml.dirs[name], nil
+}
+
 // Clear reset in-mem data
 func (ml *MemoryLoader) Clear() {
 	ml.files = nil
+	ml.dirs = nil
 }
 
 // FileLoader implements IO operation on local disk file
@@ -134,3 +163,107 @@ func (fl FileLoader) IsExist(name string) (bool, error) {
 	}
 	return true, nil
 }
+
+// IsDir returns true if given name exists and is a directory
+func (fl FileLoader) IsDir(name string) (bool, error) {
+	stat, err := os.Stat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "os.Stat name=%s", name)
+	}
+	return stat.IsDir(), nil
+}
+
+// GitLoader reads files as they were at given revision of git repository
+type GitLoader struct {
+	// Dir is working dir which relative & absolute names are resolved from
+	Dir string
+	// Rev is any revision accepted by `git show`
+	Rev string
+}
+
+// object returns `rev:./path` expression of given file name
+func (gl GitLoader) object(name string) (string, error) {
+	if filepath.IsAbs(name) {
+		rel, err := filepath.Rel(gl.Dir, name)
+		if err != nil {
+			return "", errors.Wrapf(err, "filepath.Rel name=%s", name)
+		}
+		name = rel
+	}
+	return gl.Rev + ":./" + filepath.ToSlash(name), nil
+}
+
+// git runs git command within Dir and returns its standard output
+func (gl GitLoader) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = gl.Dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "git %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// objectType returns `blob`, `tree` or empty string when name not exist
+func (gl GitLoader) objectType(name string) (string, error) {
+	obj, err := gl.object(name)
+	if err != nil {
+		return "", err
+	}
+	out, err := gl.git("cat-file", "-t", obj)
+	if err == nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+	if _, ok := errors.Cause(err).(*exec.ExitError); !ok {
+		return "", err
+	}
+	// missing path is not an error as long as revision itself is valid
+	if _, rErr := gl.git("rev-parse", "--verify", "--quiet", gl.Rev+"^{commit}"); rErr != nil {
+		return "", errors.Wrapf(rErr, "invalid revision %s", gl.Rev)
+	}
+	return "", nil
+}
+
+// ReadFile returns byte content of file at revision
+func (gl GitLoader) ReadFile(name string) ([]byte, error) {
+	obj, err := gl.object(name)
+	if err != nil {
+		return nil, err
+	}
+	return gl.git("show", obj)
+}
+
+// ReadDir returns list of files' name under specified directory at revision
+func (gl GitLoader) ReadDir(name string) ([]string, error) {
+	if typ, err := gl.objectType(name); err != nil {
+		return nil, err
+	} else if typ != "tree" {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+	obj, err := gl.object(name)
+	if err != nil {
+		return nil, err
+	}
+	out, err := gl.git("ls-tree", "--name-only", obj)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// IsExist returns true if given file name exists at revision
+func (gl GitLoader) IsExist(name string) (bool, error) {
+	typ, err := gl.objectType(name)
+	return typ != "", err
+}
+
+// IsDir returns true if given name is a directory at revision
+func (gl GitLoader) IsDir(name string) (bool, error) {
+	typ, err := gl.objectType(name)
+	return typ == "tree", err
+}
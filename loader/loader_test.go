@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
@@ -67,6 +68,16 @@ func TestMemoryLoaderReadDir(t *testing.T) {
 			},
 			want: []string{"bar", "barz"},
 		},
+		{
+			caseName: "nested_dir_listed_once",
+			input:    "foo",
+			setup: func() {
+				ds.SetFile("foo/bar/a", []byte(``))
+				ds.SetFile("foo/bar/b", []byte(``))
+				ds.SetFile("foo/barz", []byte(``))
+			},
+			want: []string{"bar", "barz"},
+		},
 		{
 			caseName: "dir_not_exist",
 			input:    "foo",
@@ -142,6 +153,68 @@ func TestMemoryLoaderIsExist(t *testing.T) {
 	}
 }
 
+func TestMemoryLoaderIsDir(t *testing.T) {
+	ds := new(MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		input    string
+		setup    func()
+		err      bool
+		want     bool
+	}{
+		{
+			caseName: "check_with_dir",
+			input:    "foo",
+			setup: func() {
+				ds.SetFile("foo/bar", []byte(``))
+			},
+			want: true,
+		},
+		{
+			caseName: "check_with_absolute_dir",
+			input:    "/foo/bar",
+			setup: func() {
+				ds.SetFile("/foo/bar/barz", []byte(``))
+			},
+			want: true,
+		},
+		{
+			caseName: "check_with_file",
+			input:    "foo/bar",
+			setup: func() {
+				ds.SetFile("foo/bar", []byte(``))
+			},
+			want: false,
+		},
+		{
+			caseName: "check_with_not_exist",
+			input:    "bar",
+			setup:    func() {},
+			want:     false,
+		},
+		{
+			caseName: "check_with_unexpected_error_raised",
+			input:    "fooz",
+			setup: func() {
+				ds.SetFile("fooz", []byte(`unexpected_error`))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			out, err := ds.IsDir(c.input)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
+
 func TestFileLoader(t *testing.T) {
 	ds := new(FileLoader)
 	tmpfile, err := ioutil.TempFile("", "zeno-test-file-loader")
@@ -181,4 +254,83 @@ func TestFileLoader(t *testing.T) {
 
 	ok, err = ds.IsExist("abcde")
 	assert.False(t, ok)
+
+	// check is dir
+	ok, err = ds.IsDir(tmpDir)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ds.IsDir(tmpfile.Name())
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = ds.IsDir("abcde")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestGitLoader(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmpDir, err := ioutil.TempDir("", "zeno-test-git-loader")
+	require.NoError(t, err)
+	defer func() {
+		rErr := os.RemoveAll(tmpDir)
+		require.NoError(t, rErr)
+	}()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tmpDir
+		out, rErr := cmd.CombinedOutput()
+		require.NoError(t, rErr, string(out))
+	}
+	run("init", "-q")
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "roles"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "roles", "requirements.yml"), []byte("old"), 0644))
+	run("add", "-A")
+	run("-c", "user.name=zeno", "-c", "user.email=zeno@example.com", "commit", "-qm", "init")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "roles", "requirements.yml"), []byte("new"), 0644))
+
+	ds := GitLoader{Dir: tmpDir, Rev: "HEAD"}
+	// read file at revision rather than working tree
+	out, err := ds.ReadFile("roles/requirements.yml")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("old"), out)
+
+	out, err = ds.ReadFile(filepath.Join(tmpDir, "roles", "requirements.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("old"), out)
+
+	_, err = ds.ReadFile("abcde")
+	assert.Error(t, err)
+
+	// read dir
+	lst, err := ds.ReadDir("roles")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"requirements.yml"}, lst)
+
+	_, err = ds.ReadDir("abcde")
+	assert.True(t, os.IsNotExist(err))
+
+	// check is exist & is dir
+	ok, err := ds.IsExist("roles/requirements.yml")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ds.IsExist("abcde")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = ds.IsDir("roles")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ds.IsDir("roles/requirements.yml")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// unknown revision
+	_, err = GitLoader{Dir: tmpDir, Rev: "unknown"}.IsExist("roles")
+	assert.Error(t, err)
 }
@@ -10,7 +10,9 @@ import (
 	"path"
 	"strings"
 
+	"github.com/meomap/zeno/inventory"
 	"github.com/meomap/zeno/loader"
+	"github.com/meomap/zeno/parser"
 	"github.com/meomap/zeno/search"
 )
 
@@ -19,6 +21,11 @@ func main() {
 		filesIn = flag.String("files", "", "names of changed files from command 'git diff $BEFORE $AFTER --name-only'")
 		debug   = flag.Bool("debug", false, "enable for verbose logging")
 		pbsIn   = flag.String("playbooks", "", "comma separated list of playbooks to examined")
+		invIn   = flag.String("inventory", "", "inventory file or dir to match group_vars/host_vars changes against targeted hosts")
+		before  = flag.String("before", "", "git revision before changes, to diff galaxy requirements files against")
+		cycles  = flag.Bool("cycle-warning", false, "warn about include cycles on stderr instead of failing on them")
+		perRole = flag.Bool("role-granularity", false, "match any change within dirs of roles used instead of files they load")
+		vaults  = flag.Bool("vault-report", false, "print vaulted files each playbook depends on to stderr")
 	)
 	flag.Parse()
 
@@ -36,7 +43,7 @@ func main() {
 	diffFiles := strings.Split(*filesIn, "\n")
 	repoDir, err := os.Getwd()
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 	lenDiffs := len(diffFiles)
 	// construct absolute path for input files
@@ -49,19 +56,52 @@ func main() {
 	log.Printf("Examine [%d] playbooks: %s\n", lenPbs, *pbsIn)
 
 	ds := new(loader.FileLoader)
+	cfg, err := parser.LoadConfig(repoDir, ds)
+	if err != nil {
+		fail(err)
+	}
+	cfg.CycleWarning = *cycles
+	cfg.RoleGranularity = *perRole
+	var inv *inventory.Inventory
+	if *invIn != "" {
+		if inv, err = inventory.Parse(path.Join(repoDir, *invIn), ds); err != nil {
+			fail(err)
+		}
+	}
+	var reqs *search.RequirementChanges
+	if *before != "" {
+		old := loader.GitLoader{Dir: repoDir, Rev: *before}
+		if reqs, err = search.DiffRequirements(diffFiles, old, ds); err != nil {
+			fail(err)
+		}
+	}
 	var (
-		matched bool
-		out     []string
+		result *parser.Playbook
+		out    []string
+		report []string
 	)
 	for i := 0; i < lenPbs; i++ {
 		name := pbFiles[i]
-		if matched, err = search.MatchPlaybook(name, diffFiles, repoDir, ds); err != nil {
-			log.Fatal(err)
-		} else if matched {
+		if result, err = parser.ParsePlaybook(name, repoDir, cfg, ds); err != nil {
+			fail(err)
+		}
+		if search.MatchParsed(name, result, diffFiles, repoDir, inv, reqs) {
 			out = append(out, name)
 		}
+		if files := search.VaultFiles(result, repoDir); *vaults && len(files) > 0 {
+			report = append(report, fmt.Sprintf("%s: %s", name, strings.Join(files, ",")))
+		}
 	}
 	fmt.Println(strings.Join(out, ","))
+	for _, line := range report {
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+// fail reports err on stderr regardless of logging & exits
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+	os.Exit(1)
 }
 
 func init() {
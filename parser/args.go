@@ -0,0 +1,71 @@
+package parser
+
+import "strings"
+
+// parseArgs splits free-form module arguments like `name=foo tasks_from="a b"`
+// into key/value pairs. Tokens without `=` are joined into `_raw_params`
+func parseArgs(raw string) map[string]string {
+	args := map[string]string{}
+	rawParams := []string{}
+	for _, token := range splitArgs(raw) {
+		idx := strings.Index(token, "=")
+		if idx <= 0 || strings.HasPrefix(token, "{{") {
+			rawParams = append(rawParams, token)
+			continue
+		}
+		args[token[:idx]] = unquote(token[idx+1:])
+	}
+	if len(rawParams) > 0 {
+		args["_raw_params"] = strings.Join(rawParams, " ")
+	}
+	return args
+}
+
+// splitArgs splits on whitespace unless inside quotes or jinja2 blocks
+func splitArgs(raw string) []string {
+	var (
+		tokens  []string
+		current []rune
+		quote   rune
+		depth   int
+		prev    rune
+	)
+	for _, c := range raw {
+		switch {
+		case quote != 0:
+			if c == quote && prev != '\\' {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case (c == ' ' || c == '\t' || c == '\n') && depth == 0:
+			if len(current) > 0 {
+				tokens = append(tokens, string(current))
+				current = current[:0]
+			}
+			prev = c
+			continue
+		}
+		current = append(current, c)
+		prev = c
+	}
+	if len(current) > 0 {
+		tokens = append(tokens, string(current))
+	}
+	return tokens
+}
+
+// unquote strips matching surrounding quotes
+func unquote(val string) string {
+	if len(val) >= 2 {
+		first, last := val[0], val[len(val)-1]
+		if first == last && (first == '"' || first == '\'') {
+			return val[1 : len(val)-1]
+		}
+	}
+	return val
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArgs(t *testing.T) {
+	for _, c := range []struct {
+		caseName string
+		raw      string
+		want     map[string]string
+	}{
+		{
+			caseName: "empty",
+			raw:      "",
+			want:     map[string]string{},
+		},
+		{
+			caseName: "single_pair",
+			raw:      "name=monitoring",
+			want:     map[string]string{"name": "monitoring"},
+		},
+		{
+			caseName: "multiple_pairs",
+			raw:      "name=db  tasks_from=replica",
+			want:     map[string]string{"name": "db", "tasks_from": "replica"},
+		},
+		{
+			caseName: "quoted_value",
+			raw:      `src="my file.j2" dest='/etc/my file'`,
+			want:     map[string]string{"src": "my file.j2", "dest": "/etc/my file"},
+		},
+		{
+			caseName: "jinja2_value",
+			raw:      "name={{ role_name | default('x') }} tasks_from=main",
+			want:     map[string]string{"name": "{{ role_name | default('x') }}", "tasks_from": "main"},
+		},
+		{
+			caseName: "raw_params",
+			raw:      "bootstrap.sh --force creates=/tmp/done",
+			want:     map[string]string{"_raw_params": "bootstrap.sh --force", "creates": "/tmp/done"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			assert.Equal(t, c.want, parseArgs(c.raw))
+		})
+	}
+}
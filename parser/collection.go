@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// collections resolved by ansible itself rather than from collections paths
+var builtinCollections = map[string]bool{
+	"ansible.builtin": true,
+	"ansible.legacy":  true,
+}
+
+var (
+	fqcnPattern      = regexp.MustCompile(`^([a-zA-Z0-9_]+)\.([a-zA-Z0-9_]+)\.([a-zA-Z0-9_.]+)$`)
+	shortNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// splitFQCN splits `namespace.collection.resource` into its components
+func splitFQCN(name string) (string, string, string, bool) {
+	m := fqcnPattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", "", false
+	}
+	return m[1], m[2], m[3], true
+}
+
+// searchCollectionPath returns dir of collection `namespace.collection`,
+// looking in `collections` dir next to playbook then configured paths
+func (w *walker) searchCollectionPath(name string, playbookRoot string) (string, error) {
+	comps := strings.SplitN(name, ".", 2)
+	if len(comps) != 2 {
+		return "", errors.Errorf("invalid collection name %s", name)
+	}
+	// content of collection refers to itself first
+	if coll, cPath := collectionOf(playbookRoot); coll == name {
+		return cPath, nil
+	}
+	searchPaths := append([]string{path.Join(playbookRoot, "collections")}, w.cfg.CollectionsPaths...)
+	for _, p := range searchPaths {
+		if path.Base(p) != "ansible_collections" {
+			p = path.Join(p, "ansible_collections")
+		}
+		cPath := path.Join(p, comps[0], comps[1])
+		if exist, err := w.ds.IsExist(cPath); err != nil {
+			return "", errors.Wrapf(err, "ds.IsExist path=%s", cPath)
+		} else if exist {
+			return cPath, nil
+		}
+	}
+	return "", &notFoundError{kind: "collection", name: name, dirs: searchPaths}
+}
+
+// collectionOf returns `namespace.collection` and dir of collection which
+// path lies inside
+func collectionOf(p string) (string, string) {
+	comps := strings.Split(p, "/")
+	for i, v := range comps {
+		if v == "ansible_collections" && i+2 < len(comps) {
+			return comps[i+1] + "." + comps[i+2], strings.Join(comps[:i+3], "/")
+		}
+	}
+	return "", ""
+}
+
+// searchPlaybookPath returns path of playbook file relative to including
+// playbook dir, or playbook `namespace.collection.name` within collection
+func (w *walker) searchPlaybookPath(name string, baseDir string, playbookRoot string) (string, error) {
+	pbPath := path.Join(baseDir, name)
+	if exist, err := w.ds.IsExist(pbPath); err != nil {
+		return "", errors.Wrapf(err, "ds.IsExist path=%s", pbPath)
+	} else if exist {
+		return pbPath, nil
+	}
+	namespace, collection, resource, ok := splitFQCN(name)
+	if !ok {
+		// leave missing file error to parser
+		return pbPath, nil
+	}
+	cPath, err := w.searchCollectionPath(namespace+"."+collection, playbookRoot)
+	if err != nil {
+		return "", errors.Wrapf(err, "searchCollectionPath name=%s", name)
+	}
+	// sub dirs of playbooks are separated by dot
+	base := path.Join(cPath, "playbooks", strings.Replace(resource, ".", "/", -1))
+	for _, ext := range []string{".yml", ".yaml"} {
+		if exist, err := w.ds.IsExist(base + ext); err != nil {
+			return "", errors.Wrapf(err, "ds.IsExist path=%s", base+ext)
+		} else if exist {
+			return base + ext, nil
+		}
+	}
+	return "", errors.Errorf("playbook %s was not found in %s", name, cPath)
+}
@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestSplitFQCN(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		ok       bool
+		resource string
+	}{
+		{name: "community.mysql.server", ok: true, resource: "server"},
+		{name: "ourorg.platform.sub.deploy", ok: true, resource: "sub.deploy"},
+		{name: "geerlingguy.java", ok: false},
+		{name: "roles/app", ok: false},
+		{name: "site.yml", ok: false},
+	} {
+		_, _, resource, ok := splitFQCN(c.name)
+		assert.Equal(t, c.ok, ok, c.name)
+		assert.Equal(t, c.resource, resource, c.name)
+	}
+}
+
+func TestCollectionOf(t *testing.T) {
+	name, dir := collectionOf("collections/ansible_collections/ourorg/platform/roles/nginx")
+	assert.Equal(t, "ourorg.platform", name)
+	assert.Equal(t, "collections/ansible_collections/ourorg/platform", dir)
+	name, _ = collectionOf("roles/nginx")
+	assert.Equal(t, "", name)
+	name, _ = collectionOf("collections/ansible_collections/ourorg")
+	assert.Equal(t, "", name)
+}
+
+func TestSearchCollectionPath(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName         string
+		name             string
+		playbookRoot     string
+		collectionsPaths []string
+		setup            func()
+		err              bool
+		want             string
+	}{
+		{
+			caseName: "next_to_playbook",
+			name:     "ourorg.platform",
+			setup: func() {
+				ds.SetFile("pb/collections/ansible_collections/ourorg/platform/galaxy.yml", []byte(""))
+			},
+			want: "pb/collections/ansible_collections/ourorg/platform",
+		},
+		{
+			caseName:         "configured_path",
+			name:             "community.mysql",
+			collectionsPaths: []string{"vendor", "shared/ansible_collections"},
+			setup: func() {
+				ds.SetFile("shared/ansible_collections/community/mysql/galaxy.yml", []byte(""))
+			},
+			want: "shared/ansible_collections/community/mysql",
+		},
+		{
+			caseName:     "content_of_same_collection",
+			name:         "ourorg.platform",
+			playbookRoot: "collections/ansible_collections/ourorg/platform/playbooks",
+			setup:        func() {},
+			want:         "collections/ansible_collections/ourorg/platform",
+		},
+		{
+			caseName: "invalid_name",
+			name:     "community",
+			setup:    func() {},
+			err:      true,
+		},
+		{
+			caseName: "not_exist",
+			name:     "community.general",
+			setup:    func() {},
+			err:      true,
+		},
+		{
+			caseName: "unexpected_error",
+			name:     "community.general",
+			setup: func() {
+				ds.SetFile("pb/collections/ansible_collections/community/general", []byte("unexpected_error"))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			w := &walker{cfg: &Config{CollectionsPaths: c.collectionsPaths}, ds: ds}
+			playbookRoot := c.playbookRoot
+			if playbookRoot == "" {
+				playbookRoot = "pb"
+			}
+			out, err := w.searchCollectionPath(c.name, playbookRoot)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
+
+func TestSearchPlaybookPath(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		name     string
+		setup    func()
+		err      bool
+		want     string
+	}{
+		{
+			caseName: "relative_file",
+			name:     "webservers.yml",
+			setup: func() {
+				ds.SetFile("pb/webservers.yml", []byte(""))
+			},
+			want: "pb/webservers.yml",
+		},
+		{
+			caseName: "missing_relative_file",
+			name:     "missing.yml",
+			setup:    func() {},
+			want:     "pb/missing.yml",
+		},
+		{
+			caseName: "collection_playbook",
+			name:     "ourorg.platform.deploy",
+			setup: func() {
+				ds.SetFile("pb/collections/ansible_collections/ourorg/platform/playbooks/deploy.yml", []byte(""))
+			},
+			want: "pb/collections/ansible_collections/ourorg/platform/playbooks/deploy.yml",
+		},
+		{
+			caseName: "collection_playbook_in_sub_dir",
+			name:     "ourorg.platform.db.backup",
+			setup: func() {
+				ds.SetFile("pb/collections/ansible_collections/ourorg/platform/playbooks/db/backup.yaml", []byte(""))
+			},
+			want: "pb/collections/ansible_collections/ourorg/platform/playbooks/db/backup.yaml",
+		},
+		{
+			caseName: "collection_playbook_not_exist",
+			name:     "ourorg.platform.missing",
+			setup: func() {
+				ds.SetFile("pb/collections/ansible_collections/ourorg/platform/galaxy.yml", []byte(""))
+			},
+			err: true,
+		},
+		{
+			caseName: "collection_not_exist",
+			name:     "ourorg.missing.deploy",
+			setup:    func() {},
+			err:      true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			w := &walker{cfg: &Config{}, ds: ds}
+			out, err := w.searchPlaybookPath(c.name, "pb", "pb")
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
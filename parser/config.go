@@ -0,0 +1,214 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/meomap/zeno/loader"
+)
+
+// Config tunes how playbook dependencies are resolved
+type Config struct {
+	// Path is ansible.cfg settings are loaded from, empty if none
+	Path string
+	// RolesPath lists dirs searched for roles after playbook `roles` dir
+	RolesPath []string
+	// CollectionsPaths lists dirs searched for collections after
+	// playbook `collections` dir
+	CollectionsPaths []string
+	// Library lists dirs searched for modules after playbook & role
+	// `library` dirs
+	Library []string
+	// FilterPlugins lists dirs searched for filters after playbook & role
+	// `filter_plugins` dirs
+	FilterPlugins []string
+	// ModuleUtils lists dirs searched for module_utils imported by modules
+	// after playbook & role `module_utils` dirs
+	ModuleUtils []string
+	// CycleWarning logs include cycles & skips revisits instead of failing
+	// as ansible allows recursive includes guarded by conditions
+	CycleWarning bool
+	// Warnings receives warnings such as skipped include cycles regardless
+	// of logging, stderr when nil
+	Warnings io.Writer
+	// RoleGranularity matches any change within dir of role used instead of
+	// just files it loads
+	RoleGranularity bool
+}
+
+// pathSetting is ansible setting of colon separated paths
+type pathSetting struct {
+	envKeys  []string
+	iniKeys  []string
+	defaults []string
+}
+
+var (
+	rolesPathSetting = pathSetting{
+		envKeys:  []string{"ANSIBLE_ROLES_PATH"},
+		iniKeys:  []string{"roles_path"},
+		defaults: []string{"~/.ansible/roles", "/usr/share/ansible/roles", "/etc/ansible/roles"},
+	}
+	collectionsPathsSetting = pathSetting{
+		envKeys:  []string{"ANSIBLE_COLLECTIONS_PATH", "ANSIBLE_COLLECTIONS_PATHS"},
+		iniKeys:  []string{"collections_path", "collections_paths"},
+		defaults: []string{"~/.ansible/collections", "/usr/share/ansible/collections"},
+	}
+	librarySetting = pathSetting{
+		envKeys:  []string{"ANSIBLE_LIBRARY"},
+		iniKeys:  []string{"library"},
+		defaults: []string{"~/.ansible/plugins/modules", "/usr/share/ansible/plugins/modules"},
+	}
+	filterPluginsSetting = pathSetting{
+		envKeys:  []string{"ANSIBLE_FILTER_PLUGINS"},
+		iniKeys:  []string{"filter_plugins"},
+		defaults: []string{"~/.ansible/plugins/filter", "/usr/share/ansible/plugins/filter"},
+	}
+	moduleUtilsSetting = pathSetting{
+		envKeys:  []string{"ANSIBLE_MODULE_UTILS"},
+		iniKeys:  []string{"module_utils"},
+		defaults: []string{"~/.ansible/plugins/module_utils", "/usr/share/ansible/plugins/module_utils"},
+	}
+)
+
+// resolve returns paths set by env, config file or default value in order
+func (ps pathSetting) resolve(defaults map[string]string, cfgPath string, cwd string) []string {
+	for _, k := range ps.envKeys {
+		if v := os.Getenv(k); v != "" {
+			return splitPathList(v, cwd)
+		}
+	}
+	for _, k := range ps.iniKeys {
+		if v, ok := defaults[k]; ok {
+			// relative paths of config file are based on its dir
+			return splitPathList(v, filepath.Dir(cfgPath))
+		}
+	}
+	return splitPathList(strings.Join(ps.defaults, ":"), cwd)
+}
+
+// LoadConfig reads ansible.cfg found in ansible lookup order: ANSIBLE_CONFIG,
+// ansible.cfg of cwd, ~/.ansible.cfg then /etc/ansible/ansible.cfg. Settings
+// from environment variables take precedence over config file
+func LoadConfig(cwd string, ds loader.DataSource) (*Config, error) {
+	cfgPath, err := searchConfigPath(cwd, ds)
+	if err != nil {
+		return nil, errors.Wrap(err, "searchConfigPath")
+	}
+	sections := map[string]map[string]string{}
+	if cfgPath != "" {
+		content, rErr := ds.ReadFile(cfgPath)
+		if rErr != nil {
+			return nil, errors.Wrapf(rErr, "dataSource file_path=%s", cfgPath)
+		}
+		if sections, err = parseINI(content); err != nil {
+			return nil, errors.Wrapf(err, "parseINI file_path=%s", cfgPath)
+		}
+	}
+	cfg := &Config{
+		Path:             cfgPath,
+		RolesPath:        rolesPathSetting.resolve(sections["defaults"], cfgPath, cwd),
+		CollectionsPaths: collectionsPathsSetting.resolve(sections["defaults"], cfgPath, cwd),
+		Library:          librarySetting.resolve(sections["defaults"], cfgPath, cwd),
+		FilterPlugins:    filterPluginsSetting.resolve(sections["defaults"], cfgPath, cwd),
+		ModuleUtils:      moduleUtilsSetting.resolve(sections["defaults"], cfgPath, cwd),
+	}
+	return cfg, nil
+}
+
+func searchConfigPath(cwd string, ds loader.DataSource) (string, error) {
+	candidates := []string{}
+	if v := os.Getenv("ANSIBLE_CONFIG"); v != "" {
+		candidates = append(candidates, expandPath(v, cwd))
+	}
+	candidates = append(candidates, path.Join(cwd, "ansible.cfg"))
+	if home := os.Getenv("HOME"); home != "" {
+		candidates = append(candidates, path.Join(home, ".ansible.cfg"))
+	}
+	candidates = append(candidates, "/etc/ansible/ansible.cfg")
+	for _, p := range candidates {
+		if exist, err := ds.IsExist(p); err != nil {
+			return "", errors.Wrapf(err, "ds.IsExist path=%s", p)
+		} else if exist {
+			return p, nil
+		}
+	}
+	return "", nil
+}
+
+// stripInlineComment removes `;` comment preceded by whitespace from line
+func stripInlineComment(line string) string {
+	for i := 1; i < len(line); i++ {
+		if line[i] == ';' && (line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitPathList splits colon separated paths, resolving them from baseDir
+func splitPathList(value string, baseDir string) []string {
+	out := []string{}
+	for _, v := range strings.Split(value, ":") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, expandPath(v, baseDir))
+		}
+	}
+	return out
+}
+
+// expandPath expands home dir & makes relative path based on baseDir
+func expandPath(p string, baseDir string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		p = path.Join(os.Getenv("HOME"), p[1:])
+	}
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(baseDir, p)
+}
+
+// parseINI reads `key = value` pairs grouped by section as python
+// ConfigParser does: indented lines continue value of previous key and
+// `;` after whitespace starts inline comment
+func parseINI(content []byte) (map[string]map[string]string, error) {
+	sections := map[string]map[string]string{}
+	current, lastKey := "", ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(stripInlineComment(raw))
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if lastKey != "" && (raw[0] == ' ' || raw[0] == '\t') {
+			sections[current][lastKey] += "\n" + line
+			continue
+		}
+		lastKey = ""
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, errors.Errorf("malformed section at line %d: %s", lineNo, line)
+			}
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		idx := strings.IndexAny(line, "=:")
+		if idx <= 0 {
+			return nil, errors.Errorf("malformed option at line %d: %s", lineNo, line)
+		}
+		if sections[current] == nil {
+			sections[current] = map[string]string{}
+		}
+		lastKey = strings.TrimSpace(line[:idx])
+		sections[current][lastKey] = strings.TrimSpace(line[idx+1:])
+	}
+	return sections, errors.Wrap(scanner.Err(), "scanner.Scan")
+}
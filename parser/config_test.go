@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestLoadConfig(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	envKeys := []string{"ANSIBLE_CONFIG", "ANSIBLE_ROLES_PATH", "ANSIBLE_COLLECTIONS_PATH", "ANSIBLE_COLLECTIONS_PATHS", "ANSIBLE_LIBRARY", "ANSIBLE_FILTER_PLUGINS", "ANSIBLE_MODULE_UTILS", "HOME"}
+	saved := map[string]string{}
+	for _, k := range envKeys {
+		saved[k] = os.Getenv(k)
+	}
+	defer func() {
+		for k, v := range saved {
+			os.Setenv(k, v)
+		}
+	}()
+	for _, c := range []struct {
+		caseName string
+		env      map[string]string
+		setup    func()
+		err      bool
+		want     []string
+		colls    []string
+		library  []string
+		filters  []string
+		utils    []string
+		path     string
+	}{
+		{
+			caseName: "roles_path_from_cwd_config",
+			env:      map[string]string{"HOME": "/home/zeno"},
+			setup: func() {
+				ds.SetFile("/repo/ansible.cfg", []byte(`
+# comment
+[defaults]
+inventory = hosts
+roles_path = ./shared-roles:./vendor-roles:~/roles:/opt/roles
+`))
+				ds.SetFile("/home/zeno/.ansible.cfg", []byte(`
+[defaults]
+roles_path = ignored`))
+			},
+			want: []string{"/repo/shared-roles", "/repo/vendor-roles", "/home/zeno/roles", "/opt/roles"},
+			path: "/repo/ansible.cfg",
+		},
+		{
+			caseName: "roles_path_continued_with_comments",
+			env:      map[string]string{"HOME": "/home/zeno"},
+			setup: func() {
+				ds.SetFile("/repo/ansible.cfg", []byte(`
+[defaults]
+roles_path = ./a: ; shared roles
+    ./b
+`))
+			},
+			want: []string{"/repo/a", "/repo/b"},
+		},
+		{
+			caseName: "roles_path_from_ansible_config_env",
+			env:      map[string]string{"ANSIBLE_CONFIG": "conf/ansible.cfg", "HOME": "/home/zeno"},
+			setup: func() {
+				ds.SetFile("/repo/conf/ansible.cfg", []byte(`
+[defaults]
+roles_path = ../roles-a`))
+				ds.SetFile("/repo/ansible.cfg", []byte(`
+[defaults]
+roles_path = ignored`))
+			},
+			want: []string{"/repo/roles-a"},
+		},
+		{
+			caseName: "roles_path_from_home_config",
+			env:      map[string]string{"HOME": "/home/zeno"},
+			setup: func() {
+				ds.SetFile("/home/zeno/.ansible.cfg", []byte(`
+[defaults]
+roles_path: roles`))
+			},
+			want: []string{"/home/zeno/roles"},
+		},
+		{
+			caseName: "roles_path_env_precedence",
+			env:      map[string]string{"ANSIBLE_ROLES_PATH": "env-roles:/abs/roles", "HOME": "/home/zeno"},
+			setup: func() {
+				ds.SetFile("/repo/ansible.cfg", []byte(`
+[defaults]
+roles_path = ./shared-roles`))
+			},
+			want: []string{"/repo/env-roles", "/abs/roles"},
+		},
+		{
+			caseName: "default_roles_path",
+			env:      map[string]string{"HOME": "/home/zeno"},
+			setup:    func() {},
+			want:     []string{"/home/zeno/.ansible/roles", "/usr/share/ansible/roles", "/etc/ansible/roles"},
+		},
+		{
+			caseName: "collections_paths_from_config",
+			env:      map[string]string{"HOME": "/home/zeno"},
+			setup: func() {
+				ds.SetFile("/repo/ansible.cfg", []byte(`
+[defaults]
+collections_paths = ./collections:~/.ansible/collections`))
+			},
+			want:  []string{"/home/zeno/.ansible/roles", "/usr/share/ansible/roles", "/etc/ansible/roles"},
+			colls: []string{"/repo/collections", "/home/zeno/.ansible/collections"},
+		},
+		{
+			caseName: "collections_path_env_precedence",
+			env:      map[string]string{"ANSIBLE_COLLECTIONS_PATHS": "/opt/collections", "HOME": "/home/zeno"},
+			setup: func() {
+				ds.SetFile("/repo/ansible.cfg", []byte(`
+[defaults]
+collections_path = ./collections`))
+			},
+			want:  []string{"/home/zeno/.ansible/roles", "/usr/share/ansible/roles", "/etc/ansible/roles"},
+			colls: []string{"/opt/collections"},
+		},
+		{
+			caseName: "default_collections_paths",
+			env:      map[string]string{"HOME": "/home/zeno"},
+			setup:    func() {},
+			want:     []string{"/home/zeno/.ansible/roles", "/usr/share/ansible/roles", "/etc/ansible/roles"},
+			colls:    []string{"/home/zeno/.ansible/collections", "/usr/share/ansible/collections"},
+		},
+		{
+			caseName: "plugin_paths_from_config",
+			env:      map[string]string{"ANSIBLE_FILTER_PLUGINS": "/opt/filters", "HOME": "/home/zeno"},
+			setup: func() {
+				ds.SetFile("/repo/ansible.cfg", []byte(`
+[defaults]
+library = ./library:/usr/share/my_modules
+module_utils = ./module_utils`))
+			},
+			want:    []string{"/home/zeno/.ansible/roles", "/usr/share/ansible/roles", "/etc/ansible/roles"},
+			library: []string{"/repo/library", "/usr/share/my_modules"},
+			filters: []string{"/opt/filters"},
+			utils:   []string{"/repo/module_utils"},
+		},
+		{
+			caseName: "default_plugin_paths",
+			env:      map[string]string{"HOME": "/home/zeno"},
+			setup:    func() {},
+			want:     []string{"/home/zeno/.ansible/roles", "/usr/share/ansible/roles", "/etc/ansible/roles"},
+			library:  []string{"/home/zeno/.ansible/plugins/modules", "/usr/share/ansible/plugins/modules"},
+			filters:  []string{"/home/zeno/.ansible/plugins/filter", "/usr/share/ansible/plugins/filter"},
+			utils:    []string{"/home/zeno/.ansible/plugins/module_utils", "/usr/share/ansible/plugins/module_utils"},
+		},
+		{
+			caseName: "malformed_config",
+			env:      map[string]string{"HOME": "/home/zeno"},
+			setup: func() {
+				ds.SetFile("/repo/ansible.cfg", []byte(`
+[defaults
+roles_path = ./shared-roles`))
+			},
+			err: true,
+		},
+		{
+			caseName: "unexpected_error_when_search_config",
+			env:      map[string]string{"HOME": "/home/zeno"},
+			setup: func() {
+				ds.SetFile("/repo/ansible.cfg", []byte("unexpected_error"))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			for _, k := range envKeys {
+				os.Setenv(k, c.env[k])
+			}
+			out, err := LoadConfig("/repo", ds)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out.RolesPath)
+				if c.colls != nil {
+					assert.Equal(t, c.colls, out.CollectionsPaths)
+				}
+				if c.library != nil {
+					assert.Equal(t, c.library, out.Library)
+				}
+				if c.filters != nil {
+					assert.Equal(t, c.filters, out.FilterPlugins)
+				}
+				if c.utils != nil {
+					assert.Equal(t, c.utils, out.ModuleUtils)
+				}
+				if c.path != "" {
+					assert.Equal(t, c.path, out.Path)
+				}
+			}
+		})
+	}
+}
+
+func TestParseINI(t *testing.T) {
+	out, err := parseINI([]byte(`
+; comment
+[defaults]
+roles_path = a:b
+forks: 10 ; inline comment
+library = ./library;./vendor
+collections_path = ./a:
+    ./b
+	; indented comment
+    ./c
+
+[ssh_connection]
+pipelining = True`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"defaults": {
+			"roles_path":       "a:b",
+			"forks":            "10",
+			"library":          "./library;./vendor",
+			"collections_path": "./a:\n./b\n./c",
+		},
+		"ssh_connection": {"pipelining": "True"},
+	}, out)
+
+	_, err = parseINI([]byte("[defaults]\nno_value"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CycleError reports files which include each other in a loop
+type CycleError struct {
+	// Chain lists files from first one of cycle back to itself
+	Chain []string
+}
+
+func (e *CycleError) Error() string {
+	return "include cycle: " + strings.Join(e.Chain, " -> ")
+}
+
+// enter pushes file onto include stack, reporting false when file is being
+// parsed already. Cycle is an error unless configured as warning
+func (w *walker) enter(file string) (bool, error) {
+	for i, f := range w.stack {
+		if f != file {
+			continue
+		}
+		chain := append(append([]string{}, w.stack[i:]...), file)
+		err := &CycleError{Chain: chain}
+		if !w.cfg.CycleWarning {
+			return false, err
+		}
+		fmt.Fprintf(w.warnings(), "Warning: skip revisit of %s\n", err)
+		return false, nil
+	}
+	w.stack = append(w.stack, file)
+	return true, nil
+}
+
+// entered reports whether file is being parsed already
+func (w *walker) entered(file string) bool {
+	for _, f := range w.stack {
+		if f == file {
+			return true
+		}
+	}
+	return false
+}
+
+// warnings returns writer of warnings configured
+func (w *walker) warnings() io.Writer {
+	if w.cfg.Warnings == nil {
+		return os.Stderr
+	}
+	return w.cfg.Warnings
+}
+
+// leave pops file most recently entered
+func (w *walker) leave() {
+	w.stack = w.stack[:len(w.stack)-1]
+}
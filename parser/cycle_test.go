@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestParsePlaybookCycles(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		playbook string
+		warning  bool
+		setup    func()
+		chain    []string
+		want     []string
+		warned   string
+	}{
+		{
+			caseName: "task_files_including_each_other",
+			playbook: "tasks_cycle.yml",
+			setup: func() {
+				ds.SetFile("tasks_cycle.yml", []byte(`
+- hosts: all
+  tasks:
+  - include_tasks: tasks/a.yml`))
+				ds.SetFile("tasks/a.yml", []byte(`
+- include_tasks: b.yml`))
+				ds.SetFile("tasks/b.yml", []byte(`
+- include_tasks: a.yml
+  when: retry | default(false)`))
+			},
+			chain: []string{"tasks/a.yml", "tasks/b.yml", "tasks/a.yml"},
+		},
+		{
+			caseName: "task_files_including_each_other_as_warning",
+			playbook: "tasks_cycle.yml",
+			warning:  true,
+			setup: func() {
+				ds.SetFile("tasks_cycle.yml", []byte(`
+- hosts: all
+  tasks:
+  - include_tasks: tasks/a.yml`))
+				ds.SetFile("tasks/a.yml", []byte(`
+- include_tasks: b.yml`))
+				ds.SetFile("tasks/b.yml", []byte(`
+- include_tasks: a.yml`))
+			},
+			want:   []string{"tasks_cycle.yml", "tasks/a.yml", "tasks/b.yml"},
+			warned: "Warning: skip revisit of include cycle: tasks/a.yml -> tasks/b.yml -> tasks/a.yml\n",
+		},
+		{
+			caseName: "role_meta_dependency_looping_back",
+			playbook: "roles_cycle.yml",
+			setup: func() {
+				ds.SetFile("roles_cycle.yml", []byte(`
+- hosts: all
+  roles: [app]`))
+				ds.SetFile("roles/app/meta/main.yml", []byte(`
+dependencies: [common]`))
+				ds.SetFile("roles/common/meta/main.yml", []byte(`
+dependencies: [app]`))
+			},
+			chain: []string{"roles/app", "roles/common", "roles/app"},
+		},
+		{
+			caseName: "role_including_itself_through_other_entry_point",
+			playbook: "self_include.yml",
+			setup: func() {
+				ds.SetFile("self_include.yml", []byte(`
+- hosts: all
+  roles: [app]`))
+				ds.SetFile("roles/app/tasks/main.yml", []byte(`
+- include_role:
+    name: app
+    tasks_from: install`))
+				ds.SetFile("roles/app/tasks/install.yml", []byte(""))
+			},
+			want: []string{"self_include.yml", "roles/app/tasks/main.yml", "roles/app/tasks/install.yml"},
+		},
+		{
+			caseName: "role_including_itself_through_same_entry_point",
+			playbook: "self_include_loop.yml",
+			setup: func() {
+				ds.SetFile("self_include_loop.yml", []byte(`
+- hosts: all
+  roles:
+  - role: app
+    tasks_from: install`))
+				ds.SetFile("roles/app/tasks/install.yml", []byte(`
+- include_role:
+    name: app
+    tasks_from: install`))
+			},
+			chain: []string{"roles/app (tasks_from=install)", "roles/app/tasks/install.yml", "roles/app (tasks_from=install)"},
+		},
+		{
+			caseName: "playbooks_importing_each_other",
+			playbook: "site.yml",
+			setup: func() {
+				ds.SetFile("site.yml", []byte(`
+- import_playbook: web.yml`))
+				ds.SetFile("web.yml", []byte(`
+- import_playbook: site.yml`))
+			},
+			chain: []string{"site.yml", "web.yml", "site.yml"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			warnings := new(bytes.Buffer)
+			out, err := ParsePlaybook(c.playbook, "", &Config{CycleWarning: c.warning, Warnings: warnings}, ds)
+			assert.Equal(t, c.warned, warnings.String())
+			if c.chain != nil {
+				require.Error(t, err)
+				cycle, ok := errors.Cause(err).(*CycleError)
+				require.True(t, ok)
+				assert.Equal(t, c.chain, cycle.Chain)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out.Files)
+			}
+		})
+	}
+}
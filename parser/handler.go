@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// handler is task run on notification along with files it loads
+type handler struct {
+	// names holds name & `listen` topics handler is notified by, names of
+	// role handlers may be prefixed with role name too
+	names []string
+	// files lists file defining handler & files it includes
+	files []string
+	// notified holds names notified by handler itself
+	notified map[string]bool
+}
+
+// notify records names notified by task, templated ones not resolvable are
+// kept as glob patterns
+func (w *walker) notify(names []string, sc scope) {
+	if w.notified == nil {
+		w.notified = map[string]bool{}
+	}
+	for _, name := range names {
+		if rendered, ok := renderString(name, sc.vars); ok {
+			w.notified[rendered] = true
+		} else {
+			w.notified[templateGlob(name, sc.vars)] = true
+		}
+	}
+}
+
+// parseHandlerFile registers handlers defined by file of role
+func (w *walker) parseHandlerFile(file string, sc scope) error {
+	if ok, err := w.enter(file); !ok {
+		return err
+	}
+	defer w.leave()
+	content, err := w.ds.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, "dataSource file_path=%s", file)
+	}
+	if w.checkVault(file, content) {
+		// encrypted handlers are opaque, counted along with role
+		return nil
+	}
+	taskList := []Task{}
+	if err = yaml.Unmarshal(content, &taskList); err != nil {
+		return errors.Wrapf(err, "yaml.Unmarshal file_path=%s", file)
+	}
+	sc.taskDir = path.Dir(file)
+	return w.parseHandlers(taskList, file, sc)
+}
+
+// parseHandlers registers handlers along with their includes, file is empty
+// for play level handlers
+func (w *walker) parseHandlers(taskList []Task, file string, sc scope) error {
+	for _, task := range taskList {
+		h := handler{names: []string{}, files: []string{}}
+		for _, name := range append([]string{task.Name}, task.Listen...) {
+			if name == "" {
+				continue
+			}
+			if rendered, ok := renderString(name, sc.vars); ok {
+				name = rendered
+			} else {
+				name = templateGlob(name, sc.vars)
+			}
+			h.names = append(h.names, name)
+			if sc.rolePath != "" {
+				h.names = append(h.names, path.Base(sc.rolePath)+" : "+name)
+			}
+		}
+		if file != "" {
+			h.files = append(h.files, file)
+		}
+		// notifications of handler count only once it's notified itself
+		outer := w.notified
+		w.notified = map[string]bool{}
+		hDeps, err := w.parseTaskList([]Task{task}, sc)
+		h.notified, w.notified = w.notified, outer
+		if err != nil {
+			return errors.Wrapf(err, "parseTaskList handler=%s", task.Name)
+		}
+		h.files = append(h.files, hDeps...)
+		w.handlers = append(w.handlers, h)
+	}
+	return nil
+}
+
+// notifiedHandlers returns files of handlers notified directly or through
+// other handlers
+func (w *walker) notifiedHandlers() []string {
+	notified := map[string]bool{}
+	for name := range w.notified {
+		notified[name] = true
+	}
+	files := []string{}
+	done := make([]bool, len(w.handlers))
+	for changed := true; changed; {
+		changed = false
+		for i, h := range w.handlers {
+			if done[i] || !h.isNotified(notified) {
+				continue
+			}
+			done[i], changed = true, true
+			files = append(files, h.files...)
+			for name := range h.notified {
+				notified[name] = true
+			}
+		}
+	}
+	return files
+}
+
+// isNotified reports whether any of notified names refers to handler, either
+// side may be glob pattern of templated name
+func (h handler) isNotified(notified map[string]bool) bool {
+	for _, name := range h.names {
+		for n := range notified {
+			if n == name {
+				return true
+			}
+			if ok, _ := path.Match(n, name); ok {
+				return true
+			}
+			if ok, _ := path.Match(name, n); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestParsePlaybookHandlers(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		playbook string
+		setup    func()
+		err      bool
+		want     []string
+	}{
+		{
+			caseName: "handler_of_other_role_notified",
+			playbook: "cross_role.yml",
+			setup: func() {
+				ds.SetFile("cross_role.yml", []byte(`
+- hosts: all
+  roles: [common, app]`))
+				ds.SetFile("roles/common/handlers/main.yml", []byte(`
+- name: restart nginx
+  include_tasks: restart.yml
+- name: reload firewall
+  include_tasks: firewall.yml`))
+				ds.SetFile("roles/common/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/common/tasks/restart.yml", []byte(""))
+				ds.SetFile("roles/common/tasks/firewall.yml", []byte(""))
+				ds.SetFile("roles/app/tasks/main.yml", []byte(`
+- template: src=site.conf dest=/etc/nginx/site.conf
+  notify: [restart nginx]`))
+			},
+			want: []string{
+				"cross_role.yml",
+				"roles/common/tasks/main.yml",
+				"roles/app/tasks/main.yml",
+				"roles/common/handlers/main.yml",
+				"roles/common/tasks/restart.yml",
+			},
+		},
+		{
+			caseName: "handlers_chained_by_listen_topics",
+			playbook: "listen.yml",
+			setup: func() {
+				ds.SetFile("listen.yml", []byte(`
+- hosts: all
+  tasks:
+  - command: /bin/deploy
+    notify: "{{ 'web changed' }}"
+  handlers:
+  - name: restart web
+    listen: web changed
+    include_tasks: tasks/restart.yml
+    notify: "db : flush cache"
+  - name: unused
+    include_tasks: tasks/unused.yml
+  roles:
+  - db`))
+				ds.SetFile("tasks/restart.yml", []byte(""))
+				ds.SetFile("tasks/unused.yml", []byte(""))
+				ds.SetFile("roles/db/handlers/main.yml", []byte(`
+- name: flush cache
+  command: /bin/flush`))
+			},
+			want: []string{"listen.yml", "tasks/restart.yml", "roles/db/handlers/main.yml"},
+		},
+		{
+			caseName: "handler_notified_by_templated_name",
+			playbook: "templated_notify.yml",
+			setup: func() {
+				ds.SetFile("templated_notify.yml", []byte(`
+- hosts: all
+  roles:
+  - role: svc
+    handlers_from: systemd`))
+				ds.SetFile("roles/svc/handlers/main.yml", []byte(`
+- name: restart svc
+  command: /bin/restart`))
+				ds.SetFile("roles/svc/handlers/systemd.yml", []byte(`
+- name: restart app service
+  systemd: name=app state=restarted`))
+				ds.SetFile("roles/svc/tasks/main.yml", []byte(`
+- command: /bin/configure
+  notify: "restart {{ service_name }} service"`))
+			},
+			want: []string{"templated_notify.yml", "roles/svc/tasks/main.yml", "roles/svc/handlers/systemd.yml"},
+		},
+		{
+			caseName: "handler_file_malformed",
+			playbook: "malformed_handlers.yml",
+			setup: func() {
+				ds.SetFile("malformed_handlers.yml", []byte(`
+- hosts: all
+  roles: [broken]`))
+				ds.SetFile("roles/broken/handlers/main.yml", []byte(`abcde`))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			out, err := ParsePlaybook(c.playbook, "", nil, ds)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out.Files)
+			}
+		})
+	}
+}
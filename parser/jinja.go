@@ -0,0 +1,393 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+var expressionPattern = regexp.MustCompile(`(?s)\{\{(.*?)\}\}`)
+
+// nested templates of variables are rendered up to this depth
+const maxRenderDepth = 10
+
+// isTemplated reports whether value contains jinja expression
+func isTemplated(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// renderString evaluates jinja expressions of s with statically known vars,
+// reporting false when any of them can't be resolved
+func renderString(s string, vars map[string]interface{}) (string, bool) {
+	return renderDepth(s, vars, 0)
+}
+
+func renderDepth(s string, vars map[string]interface{}, depth int) (string, bool) {
+	if !isTemplated(s) {
+		return s, true
+	}
+	if depth >= maxRenderDepth {
+		return "", false
+	}
+	ok := true
+	out := expressionPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if !ok {
+			return m
+		}
+		v, err := evalExpression(expressionPattern.FindStringSubmatch(m)[1], vars, depth)
+		if err != nil {
+			ok = false
+			return m
+		}
+		str, isScalar := scalarString(v)
+		if !isScalar {
+			ok = false
+			return m
+		}
+		return str
+	})
+	return out, ok
+}
+
+// templateGlob renders known expressions of s & turns others into glob
+// wildcards, variables holding templated strings are globbed in turn
+func templateGlob(s string, vars map[string]interface{}) string {
+	return globDepth(s, vars, 0)
+}
+
+func globDepth(s string, vars map[string]interface{}, depth int) string {
+	return expressionPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if depth >= maxRenderDepth {
+			return "*"
+		}
+		expr := expressionPattern.FindStringSubmatch(m)[1]
+		if v, err := evalExpression(expr, vars, depth); err == nil {
+			if str, ok := scalarString(v); ok {
+				return str
+			}
+		}
+		if val, ok := vars[strings.TrimSpace(expr)].(string); ok && isTemplated(val) {
+			return globDepth(val, vars, depth+1)
+		}
+		return "*"
+	})
+}
+
+// scalarString converts scalar value to string as jinja renders it
+func scalarString(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case bool:
+		if val {
+			return "True", true
+		}
+		return "False", true
+	case int, int64, float64:
+		return fmt.Sprint(val), true
+	}
+	return "", false
+}
+
+// undefined is value of unknown variable, only accepted by `default`
+type undefined struct{}
+
+// jinjaParser evaluates small subset of jinja expressions: literals,
+// variables with attribute & item access, `~`/`+` concatenation and common
+// string filters
+type jinjaParser struct {
+	tokens []string
+	pos    int
+	vars   map[string]interface{}
+	depth  int
+}
+
+// evalExpression returns value of expression, error if not resolvable
+func evalExpression(expr string, vars map[string]interface{}, depth int) (interface{}, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &jinjaParser{tokens: tokens, vars: vars, depth: depth}
+	v, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, errors.Errorf("unexpected token %s", p.peek())
+	}
+	if _, ok := v.(undefined); ok {
+		return nil, errors.Errorf("undefined variable in %s", expr)
+	}
+	return v, nil
+}
+
+// tokenize splits expression into names, numbers, string literals &
+// punctuations
+func tokenize(expr string) ([]string, error) {
+	tokens := []string{}
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'' || c == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != c {
+				j++
+			}
+			if j >= len(runes) {
+				return nil, errors.Errorf("unterminated string in %s", expr)
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case strings.ContainsRune("~+|.,()[]", c):
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			return nil, errors.Errorf("unsupported token %q in %s", c, expr)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *jinjaParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *jinjaParser) expect(token string) error {
+	if p.peek() != token {
+		return errors.Errorf("expected %s but got %s", token, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *jinjaParser) parseConcat() (interface{}, error) {
+	left, err := p.parseFiltered()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "~" || p.peek() == "+" {
+		p.pos++
+		right, rErr := p.parseFiltered()
+		if rErr != nil {
+			return nil, rErr
+		}
+		ls, lok := scalarString(left)
+		rs, rok := scalarString(right)
+		if !lok || !rok {
+			return nil, errors.New("concatenation of non scalar value")
+		}
+		left = ls + rs
+	}
+	return left, nil
+}
+
+func (p *jinjaParser) parseFiltered() (interface{}, error) {
+	v, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "|" {
+		p.pos++
+		name := p.peek()
+		if name == "" {
+			return nil, errors.New("missing filter name")
+		}
+		p.pos++
+		args := []interface{}{}
+		if p.peek() == "(" {
+			p.pos++
+			for p.peek() != ")" {
+				arg, aErr := p.parseConcat()
+				if aErr != nil {
+					return nil, aErr
+				}
+				args = append(args, arg)
+				if p.peek() == "," {
+					p.pos++
+				}
+			}
+			if err = p.expect(")"); err != nil {
+				return nil, err
+			}
+		}
+		if v, err = applyFilter(name, v, args); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (p *jinjaParser) parsePrimary() (interface{}, error) {
+	token := p.peek()
+	p.pos++
+	var v interface{}
+	switch {
+	case token == "":
+		return nil, errors.New("unexpected end of expression")
+	case token == "(":
+		inner, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(")"); err != nil {
+			return nil, err
+		}
+		v = inner
+	case token[0] == '\'' || token[0] == '"':
+		v = token[1 : len(token)-1]
+	case unicode.IsDigit(rune(token[0])):
+		n, err := strconv.Atoi(token)
+		if err != nil {
+			return nil, errors.Wrapf(err, "strconv.Atoi token=%s", token)
+		}
+		v = n
+	case token == "true" || token == "True":
+		v = true
+	case token == "false" || token == "False":
+		v = false
+	default:
+		val, ok := p.vars[token]
+		if !ok {
+			v = undefined{}
+			break
+		}
+		if s, isStr := val.(string); isStr && isVaulted(s) {
+			return nil, errors.Errorf("variable %s is vaulted", token)
+		} else if isStr && isTemplated(s) {
+			rendered, rOk := renderDepth(s, p.vars, p.depth+1)
+			if !rOk {
+				return nil, errors.Errorf("variable %s is not resolvable", token)
+			}
+			val = rendered
+		}
+		v = val
+	}
+	// attribute & item access
+	for p.peek() == "." || p.peek() == "[" {
+		var key interface{}
+		if p.peek() == "." {
+			p.pos++
+			if p.peek() == "" {
+				return nil, errors.New("missing attribute name")
+			}
+			key = p.peek()
+			p.pos++
+		} else {
+			p.pos++
+			k, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("]"); err != nil {
+				return nil, err
+			}
+			key = k
+		}
+		v = attribute(v, key)
+	}
+	return v, nil
+}
+
+// attribute returns item of map or list, undefined if not exist
+func attribute(v interface{}, key interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if k, ok := key.(string); ok {
+			if item, ok := val[k]; ok {
+				return item
+			}
+		}
+	case map[interface{}]interface{}:
+		if item, ok := val[key]; ok {
+			return item
+		}
+	case []interface{}:
+		if idx, ok := key.(int); ok && idx >= 0 && idx < len(val) {
+			return val[idx]
+		}
+	}
+	return undefined{}
+}
+
+// applyFilter evaluates supported filters
+func applyFilter(name string, v interface{}, args []interface{}) (interface{}, error) {
+	if _, ok := v.(undefined); ok {
+		if (name == "default" || name == "d") && len(args) > 0 {
+			return args[0], nil
+		}
+		return v, nil
+	}
+	if name == "default" || name == "d" {
+		return v, nil
+	}
+	s, ok := scalarString(v)
+	if !ok {
+		return nil, errors.Errorf("filter %s of non scalar value", name)
+	}
+	switch name {
+	case "string":
+		return s, nil
+	case "lower":
+		return strings.ToLower(s), nil
+	case "upper":
+		return strings.ToUpper(s), nil
+	case "trim":
+		return strings.TrimSpace(s), nil
+	case "basename":
+		return path.Base(s), nil
+	case "dirname":
+		return path.Dir(s), nil
+	case "replace":
+		if len(args) != 2 {
+			return nil, errors.New("replace expects 2 arguments")
+		}
+		from, fok := scalarString(args[0])
+		to, tok := scalarString(args[1])
+		if !fok || !tok {
+			return nil, errors.New("replace expects string arguments")
+		}
+		return strings.Replace(s, from, to, -1), nil
+	}
+	return nil, errors.Errorf("unsupported filter %s", name)
+}
+
+// mergeVars returns new vars where later sources take precedence
+func mergeVars(sources ...map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, src := range sources {
+		for k, v := range src {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// toVars converts decoded yaml mapping into vars
+func toVars(m map[interface{}]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for k, v := range m {
+		if key, ok := k.(string); ok {
+			out[key] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderString(t *testing.T) {
+	vars := map[string]interface{}{
+		"env":      "prod",
+		"app":      "{{ env }}_api",
+		"loop":     "{{ loop }}",
+		"port":     8080,
+		"enabled":  true,
+		"conf_dir": "/etc/app/",
+		"db": map[interface{}]interface{}{
+			"engine": "Postgres",
+		},
+		"versions": []interface{}{"9.6", "12"},
+		"secret":   "$ANSIBLE_VAULT;1.1;AES256\n6231336539\n",
+	}
+	for _, c := range []struct {
+		caseName string
+		in       string
+		ok       bool
+		want     string
+	}{
+		{caseName: "plain_string", in: "tasks/main.yml", ok: true, want: "tasks/main.yml"},
+		{caseName: "variable", in: "{{ env }}.yml", ok: true, want: "prod.yml"},
+		{caseName: "nested_variable", in: "{{ app }}.yml", ok: true, want: "prod_api.yml"},
+		{caseName: "recursive_variable", in: "{{ loop }}.yml", ok: false},
+		{caseName: "number_and_bool", in: "{{ port }}-{{ enabled }}", ok: true, want: "8080-True"},
+		{caseName: "attribute_and_item", in: "{{ db.engine | lower }}-{{ versions[1] }}-{{ db['engine'] }}", ok: true, want: "postgres-12-Postgres"},
+		{caseName: "concatenation", in: "{{ 'setup_' ~ env + '.yml' }}", ok: true, want: "setup_prod.yml"},
+		{caseName: "filters", in: "{{ conf_dir | dirname | basename | upper }}/{{ ' x ' | trim | replace('x', 'y') }}", ok: true, want: "APP/y"},
+		{caseName: "default_of_undefined", in: "{{ flavor | default('minimal') }}", ok: true, want: "minimal"},
+		{caseName: "default_of_defined", in: "{{ env | d('dev') }}", ok: true, want: "prod"},
+		{caseName: "undefined", in: "{{ ansible_os_family }}.yml", ok: false},
+		{caseName: "vaulted_variable", in: "{{ secret }}.yml", ok: false},
+		{caseName: "non_scalar", in: "{{ versions }}", ok: false},
+		{caseName: "unsupported_filter", in: "{{ env | to_json }}", ok: false},
+		{caseName: "unsupported_token", in: "{{ env if env else 'dev' }}", ok: false},
+		{caseName: "unterminated_string", in: "{{ 'prod }}", ok: false},
+		{caseName: "unsupported_operator", in: "{{ port * 2 }}", ok: false},
+		{caseName: "missing_filter_name", in: "{{ env | }}.yml", ok: false},
+		{caseName: "missing_attribute_name", in: "{{ db. }}.yml", ok: false},
+		{caseName: "unclosed_filter_arguments", in: "{{ env | replace('a', }}", ok: false},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			out, ok := renderString(c.in, vars)
+			assert.Equal(t, c.ok, ok)
+			if c.ok {
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
+
+func TestTemplateGlob(t *testing.T) {
+	vars := map[string]interface{}{
+		"env":  "prod",
+		"item": "{{ ansible_distribution }}.yml",
+	}
+	for _, c := range []struct {
+		caseName string
+		in       string
+		want     string
+	}{
+		{caseName: "plain_string", in: "main.yml", want: "main.yml"},
+		{caseName: "unresolved", in: "tasks/setup_{{ ansible_os_family | lower }}.yml", want: "tasks/setup_*.yml"},
+		{caseName: "partially_resolved", in: "{{ env }}/{{ ansible_os_family }}.yml", want: "prod/*.yml"},
+		{caseName: "templated_variable", in: "vars/{{ item }}", want: "vars/*.yml"},
+		{caseName: "malformed_expression", in: "tasks/{{ a | }}_{{ a. }}.yml", want: "tasks/*_*.yml"},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			assert.Equal(t, c.want, templateGlob(c.in, vars))
+		})
+	}
+}
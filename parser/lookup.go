@@ -0,0 +1,267 @@
+package parser
+
+import (
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Lookup is call of lookup plugin with its literal terms
+type Lookup struct {
+	Name  string
+	Terms []string
+}
+
+// lookups reading files on controller, mapped to dir searched for terms
+var fileLookups = map[string]string{
+	"file":     filesDir,
+	"fileglob": filesDir,
+	"template": templatesDir,
+}
+
+var lookupPattern = regexp.MustCompile(`\b(?:lookup|query|q)\(\s*['"]([\w.]+)['"]\s*((?:,[^)]*)?)\)`)
+
+// lookupName returns short name of file reading lookup, empty for others
+func lookupName(name string) string {
+	for _, prefix := range []string{"ansible.builtin.", "ansible.legacy."} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	if _, ok := fileLookups[name]; !ok {
+		return ""
+	}
+	return name
+}
+
+// lookupsIn returns file reading lookups called within text, terms built
+// from variables & keyword arguments are skipped
+func lookupsIn(text string) []Lookup {
+	lookups := []Lookup{}
+	for _, m := range lookupPattern.FindAllStringSubmatch(text, -1) {
+		name := lookupName(m[1])
+		if name == "" {
+			continue
+		}
+		terms := []string{}
+		for _, item := range strings.Split(strings.TrimPrefix(m[2], ","), ",") {
+			lit := stringLiteralPattern.FindStringSubmatch(strings.TrimSpace(item))
+			if lit == nil {
+				continue
+			}
+			if term := lit[1] + lit[2]; term != "" && !strings.Contains(term, "{{") {
+				terms = append(terms, term)
+			}
+		}
+		if len(terms) > 0 {
+			lookups = append(lookups, Lookup{Name: name, Terms: terms})
+		}
+	}
+	return lookups
+}
+
+// lookupsOf returns lookups called anywhere in task fields along with
+// `with_<lookup>` loops, nested task groups are left to their own tasks
+func lookupsOf(fields map[string]interface{}) []Lookup {
+	lookups := []Lookup{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch val := v.(type) {
+		case string:
+			lookups = append(lookups, lookupsIn(val)...)
+		case []interface{}:
+			for _, item := range val {
+				walk(item)
+			}
+		case map[interface{}]interface{}:
+			for _, item := range val {
+				walk(item)
+			}
+		}
+	}
+	keys := []string{}
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch k {
+		case "block", "rescue", "always":
+			continue
+		}
+		walk(fields[k])
+		if !strings.HasPrefix(k, "with_") {
+			continue
+		}
+		name := lookupName(strings.TrimPrefix(k, "with_"))
+		if name == "" {
+			continue
+		}
+		terms := []string{}
+		for _, term := range scalarsOf(fields[k]) {
+			if !strings.Contains(term, "{{") {
+				terms = append(terms, term)
+			}
+		}
+		if len(terms) > 0 {
+			lookups = append(lookups, Lookup{Name: name, Terms: terms})
+		}
+	}
+	return lookups
+}
+
+// globPath returns paths under dir matching slash separated pattern, either
+// dirs or files
+func (w *walker) globPath(dir string, pattern string, wantDir bool) ([]string, error) {
+	comps := strings.Split(pattern, "/")
+	if len(comps) > 1 && (comps[0] == "" || comps[0] == ".") {
+		return w.globPath(dir, strings.Join(comps[1:], "/"), wantDir)
+	}
+	p := path.Join(dir, comps[0])
+	candidates := []string{}
+	if strings.ContainsAny(comps[0], "*?[") {
+		entries, err := w.ds.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(errors.Cause(err)) {
+				return nil, nil
+			}
+			return nil, errors.Wrapf(err, "ds.ReadDir dir_path=%s", dir)
+		}
+		sort.Strings(entries)
+		for _, entry := range entries {
+			if ok, _ := path.Match(comps[0], entry); ok && entry != "" {
+				candidates = append(candidates, path.Join(dir, entry))
+			}
+		}
+	} else if exist, err := w.ds.IsExist(p); err != nil {
+		return nil, errors.Wrapf(err, "ds.IsExist path=%s", p)
+	} else if exist {
+		candidates = append(candidates, p)
+	}
+	matches := []string{}
+	for _, c := range candidates {
+		isDir, err := w.ds.IsDir(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ds.IsDir path=%s", c)
+		}
+		if len(comps) > 1 {
+			if isDir {
+				sub, sErr := w.globPath(c, strings.Join(comps[1:], "/"), wantDir)
+				if sErr != nil {
+					return nil, sErr
+				}
+				matches = append(matches, sub...)
+			}
+			continue
+		}
+		if isDir == wantDir {
+			matches = append(matches, c)
+		}
+	}
+	return matches, nil
+}
+
+// expandName returns names which templated name may refer to: rendered one
+// when its variables are statically known, otherwise names of files or
+// dirs matching its glob within any of dirs
+func (w *walker) expandName(name string, sc scope, dirs []string, wantDir bool) ([]string, error) {
+	if !isTemplated(name) {
+		return []string{name}, nil
+	}
+	if rendered, ok := renderString(name, sc.vars); ok {
+		return []string{rendered}, nil
+	}
+	pattern := templateGlob(name, sc.vars)
+	log.Printf("Unresolved name '%s', fallback to glob '%s'", name, pattern)
+	if path.IsAbs(pattern) {
+		dirs = []string{"/"}
+	}
+	names := []string{}
+	for _, dir := range dirs {
+		matches, err := w.globPath(dir, pattern, wantDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "globPath dir=%s pattern=%s", dir, pattern)
+		}
+		for _, m := range matches {
+			names = append(names, strings.TrimPrefix(m, dir+"/"))
+		}
+	}
+	return uniq(names), nil
+}
+
+// parseLookups resolves terms of file reading lookups within scope, seen
+// tracks templates already followed
+func (w *walker) parseLookups(lookups []Lookup, sc scope, seen map[string]bool) ([]string, error) {
+	deps := []string{}
+	for _, l := range lookups {
+		dir := fileLookups[l.Name]
+		for _, term := range l.Terms {
+			if l.Name == "fileglob" {
+				// pattern applies to files of first found dir
+				base, fErr := w.findNeedle(dir, path.Dir(term), sc)
+				if fErr != nil {
+					return nil, errors.Wrapf(fErr, "findNeedle term=%s", term)
+				}
+				if base == "" {
+					continue
+				}
+				files, gErr := w.globPath(base, path.Base(term), false)
+				if gErr != nil {
+					return nil, errors.Wrapf(gErr, "globPath term=%s", term)
+				}
+				deps = append(deps, files...)
+				continue
+			}
+			p, fErr := w.findNeedle(dir, term, sc)
+			if fErr != nil {
+				return nil, errors.Wrapf(fErr, "findNeedle term=%s", term)
+			}
+			if p == "" || seen[p] {
+				continue
+			}
+			deps = append(deps, p)
+			if l.Name == "template" {
+				tDeps, tErr := w.parseTemplate(p, sc, seen)
+				if tErr != nil {
+					return nil, errors.Wrapf(tErr, "parseTemplate term=%s", term)
+				}
+				deps = append(deps, tDeps...)
+			}
+		}
+	}
+	return deps, nil
+}
+
+// parseFileLookups returns files read by lookups within existing files such
+// as vars files
+func (w *walker) parseFileLookups(files []string, sc scope) ([]string, error) {
+	deps := []string{}
+	for _, f := range files {
+		if exist, err := w.ds.IsExist(f); err != nil {
+			return nil, errors.Wrapf(err, "ds.IsExist path=%s", f)
+		} else if !exist {
+			continue
+		}
+		if isDir, err := w.ds.IsDir(f); err != nil {
+			return nil, errors.Wrapf(err, "ds.IsDir path=%s", f)
+		} else if isDir {
+			continue
+		}
+		content, err := w.ds.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "dataSource file_path=%s", f)
+		}
+		if w.checkVault(f, content) {
+			continue
+		}
+		lDeps, err := w.parseLookups(lookupsIn(string(content)), sc, map[string]bool{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "parseLookups file_path=%s", f)
+		}
+		deps = append(deps, lDeps...)
+	}
+	return deps, nil
+}
@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestLookupsIn(t *testing.T) {
+	text := `
+key: "{{ lookup('file', 'keys/deploy.pub') }}"
+conf: "{{ lookup('ansible.builtin.template', 'app.j2', template_vars=dict(a=1)) }}"
+confs: "{{ query('fileglob', 'conf.d/*.conf') }}"
+both: "{{ q('file', 'a.txt', 'b.txt', errors='ignore') }}"
+dynamic: "{{ lookup('file', name ~ '.pub') }}"
+env: "{{ lookup('env', 'HOME') }}"`
+	assert.Equal(t, []Lookup{
+		{Name: "file", Terms: []string{"keys/deploy.pub"}},
+		{Name: "template", Terms: []string{"app.j2"}},
+		{Name: "fileglob", Terms: []string{"conf.d/*.conf"}},
+		{Name: "file", Terms: []string{"a.txt", "b.txt"}},
+	}, lookupsIn(text))
+}
+
+func TestTaskLookups(t *testing.T) {
+	task := Task{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+authorized_key:
+  user: deploy
+  key: "{{ item }}"
+with_file:
+- keys/alice.pub
+- "{{ extra_key }}"
+with_items: "{{ lookup('fileglob', 'keys/*.pub') }}"
+block:
+- debug: msg="{{ lookup('file', 'nested.txt') }}"`), &task))
+	assert.Equal(t, []Lookup{
+		{Name: "file", Terms: []string{"keys/alice.pub"}},
+		{Name: "fileglob", Terms: []string{"keys/*.pub"}},
+	}, task.Lookups)
+}
+
+func TestParseLookups(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	sc := scope{playbookRoot: "pb", rolePath: "pb/roles/web"}
+	for _, c := range []struct {
+		caseName string
+		lookups  []Lookup
+		setup    func()
+		err      bool
+		want     []string
+	}{
+		{
+			caseName: "file_of_role_then_playbook",
+			lookups:  []Lookup{{Name: "file", Terms: []string{"deploy.pub", "shared.pub", "missing.pub"}}},
+			setup: func() {
+				ds.SetFile("pb/roles/web/files/deploy.pub", []byte(""))
+				ds.SetFile("pb/files/shared.pub", []byte(""))
+			},
+			want: []string{"pb/roles/web/files/deploy.pub", "pb/files/shared.pub"},
+		},
+		{
+			caseName: "template_with_includes",
+			lookups:  []Lookup{{Name: "template", Terms: []string{"app.j2"}}},
+			setup: func() {
+				ds.SetFile("pb/roles/web/templates/app.j2", []byte(`
+{% include 'header.j2' %}
+{{ lookup('file', 'banner.txt') }}`))
+				ds.SetFile("pb/roles/web/templates/header.j2", []byte(""))
+				ds.SetFile("pb/roles/web/files/banner.txt", []byte(""))
+			},
+			want: []string{
+				"pb/roles/web/templates/app.j2",
+				"pb/roles/web/files/banner.txt",
+				"pb/roles/web/templates/header.j2",
+			},
+		},
+		{
+			caseName: "fileglob_expanded",
+			lookups:  []Lookup{{Name: "fileglob", Terms: []string{"conf.d/*.conf"}}},
+			setup: func() {
+				ds.SetFile("pb/roles/web/files/conf.d/b.conf", []byte(""))
+				ds.SetFile("pb/roles/web/files/conf.d/a.conf", []byte(""))
+				ds.SetFile("pb/roles/web/files/conf.d/readme.md", []byte(""))
+				ds.SetFile("pb/roles/web/files/conf.d/sub.conf/c.conf", []byte(""))
+			},
+			want: []string{"pb/roles/web/files/conf.d/a.conf", "pb/roles/web/files/conf.d/b.conf"},
+		},
+		{
+			caseName: "fileglob_dir_not_exist",
+			lookups:  []Lookup{{Name: "fileglob", Terms: []string{"missing/*"}}},
+			setup:    func() {},
+			want:     []string{},
+		},
+		{
+			caseName: "unexpected_error",
+			lookups:  []Lookup{{Name: "fileglob", Terms: []string{"conf.d/*"}}},
+			setup: func() {
+				ds.SetFile("pb/roles/web/files/conf.d", []byte("unexpected_error"))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			w := &walker{cfg: &Config{}, ds: ds}
+			out, err := w.parseLookups(c.lookups, sc, map[string]bool{})
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
+
+func TestParseFileLookups(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	ds.SetFile("pb/vars/main.yml", []byte(`
+ssh_key: "{{ lookup('file', 'keys/deploy.pub') }}"`))
+	ds.SetFile("pb/files/keys/deploy.pub", []byte(""))
+	w := &walker{cfg: &Config{}, ds: ds}
+	out, err := w.parseFileLookups([]string{"pb/vars/missing.yml", "pb/vars", "pb/vars/main.yml"}, scope{playbookRoot: "pb"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pb/files/keys/deploy.pub"}, out)
+}
+
+func TestGlobPath(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	ds.SetFile("pb/tasks/setup_debian.yml", []byte(""))
+	ds.SetFile("pb/tasks/setup_redhat.yml", []byte(""))
+	ds.SetFile("pb/tasks/main.yml", []byte(""))
+	ds.SetFile("pb/vars/dev/main.yml", []byte(""))
+	ds.SetFile("pb/vars/prod/main.yml", []byte(""))
+	ds.SetFile("pb/vars/prod.yml", []byte(""))
+	ds.SetFile("broken/a.yml", []byte("unexpected_error"))
+	for _, c := range []struct {
+		caseName string
+		dir      string
+		pattern  string
+		wantDir  bool
+		err      bool
+		want     []string
+	}{
+		{caseName: "files", dir: "pb", pattern: "tasks/setup_*.yml", want: []string{"pb/tasks/setup_debian.yml", "pb/tasks/setup_redhat.yml"}},
+		{caseName: "nested_wildcards", dir: "pb", pattern: "./*/*/main.yml", want: []string{"pb/vars/dev/main.yml", "pb/vars/prod/main.yml"}},
+		{caseName: "dirs_only", dir: "pb", pattern: "vars/*", wantDir: true, want: []string{"pb/vars/dev", "pb/vars/prod"}},
+		{caseName: "literal_path", dir: "pb", pattern: "tasks/main.yml", want: []string{"pb/tasks/main.yml"}},
+		{caseName: "no_match", dir: "pb", pattern: "handlers/*.yml", want: []string{}},
+		{caseName: "unexpected_error", dir: "broken", pattern: "*", err: true},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			w := &walker{cfg: &Config{}, ds: ds}
+			out, err := w.globPath(c.dir, c.pattern, c.wantDir)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
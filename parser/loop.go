@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// loop keywords mapped to how their items are built
+var loopKinds = map[string]string{
+	"loop":             "list",
+	"with_list":        "list",
+	"with_items":       "items",
+	"with_first_found": "first_found",
+}
+
+var (
+	// first_found lookup with its single argument captured
+	firstFoundPattern = regexp.MustCompile(`^\{\{-?\s*(?:lookup|query|q)\(\s*['"](?:ansible\.builtin\.)?first_found['"]\s*,\s*(.+?)\s*\)\s*-?\}\}$`)
+	identPattern      = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+)
+
+// Loop is loop of task along with its variable name
+type Loop struct {
+	// Kind tells how items are built: list, items or first_found
+	Kind  string
+	Value interface{}
+	Var   string
+}
+
+// loopOf returns loop declared by task fields, nil if none
+func loopOf(fields map[string]interface{}) *Loop {
+	for key, kind := range loopKinds {
+		v, ok := fields[key]
+		if !ok || v == nil {
+			continue
+		}
+		l := &Loop{Kind: kind, Value: v, Var: "item"}
+		if lc, ok := fields["loop_control"].(map[interface{}]interface{}); ok {
+			if name, ok := lc["loop_var"].(string); ok && name != "" {
+				l.Var = name
+			}
+		}
+		return l
+	}
+	return nil
+}
+
+// items returns literal items of loop, nil when not known statically, and
+// whether they are first_found candidates which may not exist
+func (l *Loop) items(vars map[string]interface{}) ([]interface{}, bool) {
+	if l.Kind == "first_found" {
+		return firstFoundCandidates(l.Value, vars), true
+	}
+	v := l.Value
+	if s, ok := v.(string); ok {
+		if candidates, ok := firstFoundOf(s, vars); ok {
+			return candidates, true
+		}
+		m := expressionPattern.FindStringSubmatch(s)
+		if m == nil || m[0] != strings.TrimSpace(s) {
+			return nil, false
+		}
+		if v, _ = evalExpression(m[1], vars, 0); v == nil {
+			return nil, false
+		}
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	if l.Kind != "items" {
+		return list, false
+	}
+	// with_items flattens nested lists by one level
+	items := []interface{}{}
+	for _, item := range list {
+		if sub, ok := item.([]interface{}); ok {
+			items = append(items, sub...)
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, false
+}
+
+// firstFoundOf returns candidates of first_found lookup which whole s is
+// made of, false if s isn't such lookup
+func firstFoundOf(s string, vars map[string]interface{}) ([]interface{}, bool) {
+	m := firstFoundPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, false
+	}
+	var term interface{}
+	if identPattern.MatchString(m[1]) {
+		v, ok := vars[m[1]]
+		if !ok {
+			return nil, true
+		}
+		term = v
+	} else if err := yaml.Unmarshal([]byte(m[1]), &term); err != nil {
+		// not a literal, unknown until runtime
+		return nil, true
+	}
+	return firstFoundCandidates(term, vars), true
+}
+
+// firstFoundCandidates returns every file which first_found term may pick,
+// term is either file name, list of them or mapping of files & paths
+func firstFoundCandidates(term interface{}, vars map[string]interface{}) []interface{} {
+	candidates := []interface{}{}
+	switch val := term.(type) {
+	case string:
+		if m := expressionPattern.FindStringSubmatch(val); m != nil && m[0] == val {
+			if v, ok := vars[strings.TrimSpace(m[1])]; ok {
+				return firstFoundCandidates(v, vars)
+			}
+		}
+		candidates = append(candidates, val)
+	case []interface{}:
+		for _, item := range val {
+			candidates = append(candidates, firstFoundCandidates(item, vars)...)
+		}
+	case map[interface{}]interface{}:
+		files := splitTerms(val["files"], ",;")
+		paths := splitTerms(val["paths"], ",:;")
+		if len(paths) == 0 {
+			for _, f := range files {
+				candidates = append(candidates, f)
+			}
+			break
+		}
+		for _, p := range paths {
+			for _, f := range files {
+				candidates = append(candidates, strings.TrimSuffix(p, "/")+"/"+f)
+			}
+		}
+	}
+	return candidates
+}
+
+// splitTerms returns scalars of list or separated string
+func splitTerms(v interface{}, seps string) []string {
+	out := []string{}
+	for _, s := range scalarsOf(v) {
+		if _, isList := v.([]interface{}); isList || isTemplated(s) {
+			out = append(out, s)
+			continue
+		}
+		for _, item := range strings.FieldsFunc(s, func(r rune) bool { return strings.ContainsRune(seps, r) }) {
+			out = append(out, strings.TrimSpace(item))
+		}
+	}
+	return out
+}
+
+// loopScopes returns one scope per loop item with loop variable set, just
+// given scope for tasks without loop or with items unknown statically, and
+// whether items are first_found candidates
+func loopScopes(l *Loop, sc scope) ([]scope, bool) {
+	if l == nil {
+		return []scope{sc}, false
+	}
+	items, optional := l.items(sc.vars)
+	if len(items) == 0 {
+		return []scope{sc}, false
+	}
+	scopes := []scope{}
+	for _, item := range items {
+		isc := sc
+		isc.vars = mergeVars(sc.vars, map[string]interface{}{l.Var: item})
+		scopes = append(scopes, isc)
+	}
+	return scopes, optional
+}
+
+// includeNames returns names which include may refer to, expanding whole
+// first_found lookup into its candidates which may not exist
+func includeNames(name string, sc scope) ([]string, bool) {
+	candidates, ok := firstFoundOf(name, sc.vars)
+	if !ok {
+		return []string{name}, false
+	}
+	names := []string{}
+	for _, c := range candidates {
+		if s, ok := scalarString(c); ok {
+			names = append(names, s)
+		}
+	}
+	return names, true
+}
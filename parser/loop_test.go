@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestLoopItems(t *testing.T) {
+	vars := map[string]interface{}{
+		"users":  []interface{}{"alice", "bob"},
+		"params": map[interface{}]interface{}{"files": []interface{}{"a.yml", "b.yml"}, "paths": "vars:extra/"},
+	}
+	for _, c := range []struct {
+		caseName string
+		task     string
+		optional bool
+		want     []interface{}
+	}{
+		{
+			caseName: "task_without_loop",
+			task:     `debug: msg=hi`,
+		},
+		{
+			caseName: "literal_loop",
+			task:     `{debug: msg=hi, loop: [a, b]}`,
+			want:     []interface{}{"a", "b"},
+		},
+		{
+			caseName: "with_items_flattened",
+			task:     `{debug: msg=hi, with_items: [a, [b, c]]}`,
+			want:     []interface{}{"a", "b", "c"},
+		},
+		{
+			caseName: "loop_of_known_variable",
+			task:     `{debug: msg=hi, loop: "{{ users }}"}`,
+			want:     []interface{}{"alice", "bob"},
+		},
+		{
+			caseName: "loop_of_unknown_variable",
+			task:     `{debug: msg=hi, loop: "{{ groups['all'] }}"}`,
+		},
+		{
+			caseName: "with_first_found_list",
+			task:     `{debug: msg=hi, with_first_found: ["{{ os }}.yml", default.yml]}`,
+			optional: true,
+			want:     []interface{}{"{{ os }}.yml", "default.yml"},
+		},
+		{
+			caseName: "with_first_found_files_and_paths",
+			task:     `{debug: msg=hi, with_first_found: [{files: "a.yml,b.yml", paths: [vars, extra]}]}`,
+			optional: true,
+			want:     []interface{}{"vars/a.yml", "vars/b.yml", "extra/a.yml", "extra/b.yml"},
+		},
+		{
+			caseName: "loop_of_first_found_query",
+			task:     `{debug: msg=hi, loop: "{{ query('first_found', params) }}"}`,
+			optional: true,
+			want:     []interface{}{"vars/a.yml", "vars/b.yml", "extra/a.yml", "extra/b.yml"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			task := Task{}
+			require.NoError(t, yaml.Unmarshal([]byte(c.task), &task))
+			if task.Loop == nil {
+				assert.Nil(t, c.want)
+				return
+			}
+			items, optional := task.Loop.items(vars)
+			assert.Equal(t, c.optional, optional)
+			assert.Equal(t, c.want, items)
+		})
+	}
+}
+
+func TestIncludeNames(t *testing.T) {
+	sc := scope{vars: map[string]interface{}{"candidates": []interface{}{"a.yml", "{{ os }}.yml"}}}
+	for _, c := range []struct {
+		caseName string
+		name     string
+		optional bool
+		want     []string
+	}{
+		{caseName: "plain_name", name: "main.yml", want: []string{"main.yml"}},
+		{caseName: "templated_name", name: "{{ os }}.yml", want: []string{"{{ os }}.yml"}},
+		{caseName: "first_found_of_variable", name: "{{ lookup('first_found', candidates) }}", optional: true, want: []string{"a.yml", "{{ os }}.yml"}},
+		{caseName: "first_found_of_literal", name: "{{ lookup('ansible.builtin.first_found', ['a.yml', 'b.yml']) }}", optional: true, want: []string{"a.yml", "b.yml"}},
+		{caseName: "first_found_of_unknown", name: "{{ lookup('first_found', unknown) }}", optional: true, want: []string{}},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			names, optional := includeNames(c.name, sc)
+			assert.Equal(t, c.optional, optional)
+			assert.Equal(t, c.want, names)
+		})
+	}
+}
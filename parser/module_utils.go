@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// dir of shared python code imported by modules & plugins
+const moduleUtilsDir = "module_utils"
+
+var (
+	fromImportPattern = regexp.MustCompile(`(?m)^[ \t]*from[ \t]+(\.*[\w.]*)[ \t]+import[ \t]+(\([^)]*\)|[^\n#]+)`)
+	importPattern     = regexp.MustCompile(`(?m)^[ \t]*import[ \t]+([^\n#]+)`)
+)
+
+// pyImport is python import statement, names are empty for `import x`
+type pyImport struct {
+	module string
+	names  []string
+}
+
+// pythonImports returns import statements of python source
+func pythonImports(content []byte) []pyImport {
+	imports := []pyImport{}
+	splitNames := func(raw string) []string {
+		names := []string{}
+		raw = strings.Trim(strings.TrimSpace(raw), "()")
+		for _, line := range strings.Split(raw, "\n") {
+			// comments inside parenthesized names
+			if i := strings.Index(line, "#"); i >= 0 {
+				line = line[:i]
+			}
+			for _, v := range strings.Split(line, ",") {
+				if fields := strings.Fields(v); len(fields) > 0 {
+					names = append(names, fields[0])
+				}
+			}
+		}
+		return names
+	}
+	for _, m := range fromImportPattern.FindAllStringSubmatch(string(content), -1) {
+		imports = append(imports, pyImport{module: m[1], names: splitNames(m[2])})
+	}
+	for _, m := range importPattern.FindAllStringSubmatch(string(content), -1) {
+		for _, name := range splitNames(m[1]) {
+			imports = append(imports, pyImport{module: name})
+		}
+	}
+	return imports
+}
+
+var collectionModuleUtilsPattern = regexp.MustCompile(`^ansible_collections\.(\w+)\.(\w+)\.plugins\.module_utils(\..+)?$`)
+
+// moduleUtilsCandidates returns dirs & relative dotted name of module_utils
+// a python module refers to, file is path of module importing it
+func (w *walker) moduleUtilsCandidates(module string, file string, sc scope) ([]string, string) {
+	switch {
+	case module == "ansible.module_utils" || strings.HasPrefix(module, "ansible.module_utils."):
+		return w.pluginDirs(moduleUtilsDir, sc), strings.TrimPrefix(strings.TrimPrefix(module, "ansible.module_utils"), ".")
+	case collectionModuleUtilsPattern.MatchString(module):
+		m := collectionModuleUtilsPattern.FindStringSubmatch(module)
+		cPath, err := w.searchCollectionPath(m[1]+"."+m[2], sc.playbookRoot)
+		if err != nil {
+			// collection installed outside of repository
+			return nil, ""
+		}
+		return []string{path.Join(cPath, "plugins", moduleUtilsDir)}, strings.TrimPrefix(m[3], ".")
+	case strings.HasPrefix(module, "."):
+		// relative import within collection plugins
+		dir := path.Dir(file)
+		rest := strings.TrimLeft(module, ".")
+		for i := 1; i < len(module)-len(rest); i++ {
+			dir = path.Dir(dir)
+		}
+		return []string{dir}, rest
+	}
+	return nil, ""
+}
+
+// findPythonModule returns file of dotted module name within dirs, either
+// `a/b.py` or package `a/b/__init__.py`
+func (w *walker) findPythonModule(dirs []string, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	rel := strings.Replace(name, ".", "/", -1)
+	for _, dir := range dirs {
+		for _, p := range []string{path.Join(dir, rel+".py"), path.Join(dir, rel, "__init__.py")} {
+			if exist, err := w.ds.IsExist(p); err != nil {
+				return "", errors.Wrapf(err, "ds.IsExist path=%s", p)
+			} else if exist {
+				return p, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// parseModuleUtils returns local module_utils imported by python file and
+// their own imports recursively
+func (w *walker) parseModuleUtils(file string, sc scope, seen map[string]bool) ([]string, error) {
+	if seen[file] || path.Ext(file) != ".py" {
+		return nil, nil
+	}
+	seen[file] = true
+	content, err := w.ds.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dataSource file_path=%s", file)
+	}
+	deps := []string{}
+	for _, imp := range pythonImports(content) {
+		dirs, rel := w.moduleUtilsCandidates(imp.module, file, sc)
+		if len(dirs) == 0 {
+			continue
+		}
+		// imported names may be sub modules or symbols of module
+		found := []string{}
+		for _, name := range imp.names {
+			sub := name
+			if rel != "" {
+				sub = rel + "." + name
+			}
+			p, fErr := w.findPythonModule(dirs, sub)
+			if fErr != nil {
+				return nil, errors.Wrapf(fErr, "findPythonModule name=%s", sub)
+			}
+			if p != "" {
+				found = append(found, p)
+			}
+		}
+		if len(found) == 0 {
+			p, fErr := w.findPythonModule(dirs, rel)
+			if fErr != nil {
+				return nil, errors.Wrapf(fErr, "findPythonModule name=%s", rel)
+			}
+			if p != "" {
+				found = append(found, p)
+			}
+		}
+		for _, p := range found {
+			if seen[p] {
+				continue
+			}
+			sub, sErr := w.parseModuleUtils(p, sc, seen)
+			if sErr != nil {
+				return nil, errors.Wrapf(sErr, "parseModuleUtils file_path=%s", p)
+			}
+			deps = append(deps, p)
+			deps = append(deps, sub...)
+		}
+	}
+	return deps, nil
+}
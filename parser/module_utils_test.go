@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestPythonImports(t *testing.T) {
+	content := []byte(`#!/usr/bin/python
+from __future__ import absolute_import
+import os, json as j
+from ansible.module_utils.basic import AnsibleModule
+from ansible.module_utils import our_common, our_net  # helpers
+from ansible.module_utils.our_pkg.sub import (
+    helper,  # first
+    other,
+)
+from ..module_utils.client import Client
+
+def main():
+    import ansible.module_utils.lazy
+`)
+	assert.Equal(t, []pyImport{
+		{module: "__future__", names: []string{"absolute_import"}},
+		{module: "ansible.module_utils.basic", names: []string{"AnsibleModule"}},
+		{module: "ansible.module_utils", names: []string{"our_common", "our_net"}},
+		{module: "ansible.module_utils.our_pkg.sub", names: []string{"helper", "other"}},
+		{module: "..module_utils.client", names: []string{"Client"}},
+		{module: "os"},
+		{module: "json"},
+		{module: "ansible.module_utils.lazy"},
+	}, pythonImports(content))
+}
+
+func TestParseModuleUtils(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		file     string
+		sc       scope
+		setup    func()
+		err      bool
+		want     []string
+	}{
+		{
+			caseName: "import_closure",
+			file:     "pb/library/our_dns.py",
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/library/our_dns.py", []byte(`
+from ansible.module_utils.basic import AnsibleModule
+from ansible.module_utils.our_common import retry`))
+				ds.SetFile("pb/module_utils/our_common.py", []byte(`
+from ansible.module_utils import our_http`))
+				ds.SetFile("pb/module_utils/our_http.py", []byte(`
+from ansible.module_utils.our_common import retry`))
+				ds.SetFile("pb/module_utils/unused.py", []byte(""))
+			},
+			want: []string{"pb/module_utils/our_common.py", "pb/module_utils/our_http.py"},
+		},
+		{
+			caseName: "package_of_role",
+			file:     "pb/roles/dns/library/our_dns.py",
+			sc:       scope{playbookRoot: "pb", rolePath: "pb/roles/dns"},
+			setup: func() {
+				ds.SetFile("pb/roles/dns/library/our_dns.py", []byte(`
+import ansible.module_utils.dns_client`))
+				ds.SetFile("pb/roles/dns/module_utils/dns_client/__init__.py", []byte(""))
+			},
+			want: []string{"pb/roles/dns/module_utils/dns_client/__init__.py"},
+		},
+		{
+			caseName: "collection_imports",
+			file:     "pb/collections/ansible_collections/ourorg/platform/plugins/modules/dns.py",
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/collections/ansible_collections/ourorg/platform/plugins/modules/dns.py", []byte(`
+from ansible_collections.ourorg.platform.plugins.module_utils.client import Client
+from ..module_utils.retry import retry
+from ansible_collections.community.general.plugins.module_utils import other`))
+				ds.SetFile("pb/collections/ansible_collections/ourorg/platform/plugins/module_utils/client.py", []byte(""))
+				ds.SetFile("pb/collections/ansible_collections/ourorg/platform/plugins/module_utils/retry.py", []byte(""))
+			},
+			want: []string{
+				"pb/collections/ansible_collections/ourorg/platform/plugins/module_utils/client.py",
+				"pb/collections/ansible_collections/ourorg/platform/plugins/module_utils/retry.py",
+			},
+		},
+		{
+			caseName: "not_python_module",
+			file:     "pb/library/our_dns.ps1",
+			sc:       scope{playbookRoot: "pb"},
+			setup:    func() {},
+			want:     nil,
+		},
+		{
+			caseName: "module_not_exist",
+			file:     "pb/library/our_dns.py",
+			sc:       scope{playbookRoot: "pb"},
+			setup:    func() {},
+			err:      true,
+		},
+		{
+			caseName: "unexpected_error",
+			file:     "pb/library/our_dns.py",
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/library/our_dns.py", []byte(`
+from ansible.module_utils.our_common import retry`))
+				ds.SetFile("pb/module_utils/our_common.py", []byte("unexpected_error"))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			w := &walker{cfg: &Config{}, ds: ds}
+			out, err := w.parseModuleUtils(c.file, c.sc, map[string]bool{})
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
@@ -2,9 +2,9 @@ package parser
 
 import (
 	"log"
-	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
@@ -12,139 +12,261 @@ import (
 	"github.com/meomap/zeno/loader"
 )
 
-// Task with file includes
-type Task struct {
-	Name         string `yaml:"name"`
-	IncludeTasks string `yaml:"include_tasks"`
-	ImportTasks  string `yaml:"import_tasks"`
-	Include      string `yaml:"include"`
+// Play composites of multiple roles & tasks, or refers to another playbook
+// with `import_playbook` or legacy `include`
+type Play struct {
+	Hosts          StringList             `yaml:"hosts"`
+	Collections    []string               `yaml:"collections"`
+	Vars           map[string]interface{} `yaml:"vars"`
+	Roles          []Role                 `yaml:"roles"`
+	PreTasks       []Task                 `yaml:"pre_tasks"`
+	Tasks          []Task                 `yaml:"tasks"`
+	PostTasks      []Task                 `yaml:"post_tasks"`
+	Handlers       []Task                 `yaml:"handlers"`
+	VarsFiles      []StringList           `yaml:"vars_files"`
+	ImportPlaybook string                 `yaml:"import_playbook"`
+	Include        string                 `yaml:"include"`
 }
 
-// Role may define tasks include/import
-type Role struct {
-	Name string `yaml:"role"`
+// UnmarshalYAML accepts `import_playbook` given by fully qualified name
+func (p *Play) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Play
+	fields := map[string]interface{}{}
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+	return unmarshalNormalized(unmarshal, fields, (*plain)(p))
 }
 
-// Play composites of multiple roles & tasks
-type Play struct {
-	Roles []Role `yaml:"roles"`
+// Playbook is what a playbook file depends on
+type Playbook struct {
+	// Files lists dirs/files used by playbook
+	Files []string
+	// Hosts lists host patterns targeted by plays
+	Hosts []string
+	// Roles lists dir names of roles used outside of collections
+	Roles []string
+	// Collections lists `namespace.collection` of collections used
+	Collections []string
+	// Vaults lists files used by playbook which hold vault payloads
+	Vaults []string
 }
 
-// ParsePlaybook returns list of dirs/files used by current playbook
-func ParsePlaybook(filePath string, repoDir string, ds loader.DataSource) ([]string, error) {
+// walker resolves dependencies sharing the same settings & data source
+type walker struct {
+	cfg *Config
+	ds  loader.DataSource
+	// roles & collections referenced so far
+	roles       []string
+	collections []string
+	// rolePaths lists dirs of roles loaded so far, whose plugins become
+	// available to later tasks
+	rolePaths []string
+	// plugins caches plugin name to file mapping of plugin dirs
+	plugins map[string]map[string]string
+	// stack lists playbooks, roles & task files being parsed
+	stack []string
+	// handlers defined within current play & names notified by its tasks
+	handlers []handler
+	notified map[string]bool
+	// vaults lists files read so far holding vault payloads
+	vaults []string
+}
+
+// ParsePlaybook returns list of dirs/files used by current playbook along
+// with hosts targeted by its plays. Default settings apply with nil cfg
+func ParsePlaybook(filePath string, repoDir string, cfg *Config, ds loader.DataSource) (*Playbook, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	w := &walker{cfg: cfg, ds: ds}
+	return w.parsePlaybook(filePath, repoDir)
+}
+
+func (w *walker) parsePlaybook(filePath string, repoDir string) (*Playbook, error) {
 	log.Printf("Parse playbook '%s'", filePath)
-	content, err := ds.ReadFile(filePath)
+	pbPath := filePath
+	if !path.IsAbs(pbPath) {
+		pbPath = path.Join(repoDir, filePath)
+	}
+	// stack holds paths as globbed from playbook dir
+	if ok, err := w.enter(pbPath); !ok {
+		return &Playbook{}, err
+	}
+	defer w.leave()
+	content, err := w.ds.ReadFile(filePath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "dataSource file_path=%s", filePath)
 	}
 	playbook := []Play{}
-	if err = yaml.Unmarshal(content, &playbook); err != nil {
+	if w.checkVault(pbPath, content) {
+		log.Printf("Skip vaulted playbook '%s'", filePath)
+	} else if err = yaml.Unmarshal(content, &playbook); err != nil {
 		return nil, errors.Wrapf(err, "yaml.Unmarshal file_path=%s", filePath)
 	}
-	playbookRoot := filepath.Dir(path.Join(repoDir, filePath))
-	deps := []string{playbookRoot}
+	playbookRoot := filepath.Dir(pbPath)
+	// playbook dir as a whole would match any change next to playbook
+	deps := []string{pbPath}
+	if p := w.cfg.Path; repoDir != "" && strings.HasPrefix(p, strings.TrimSuffix(repoDir, "/")+"/") {
+		// settings of repository change how everything is resolved
+		deps = append(deps, p)
+	}
+	aDeps, err := w.adjacentDirs(playbookRoot)
+	if err != nil {
+		return nil, errors.Wrapf(err, "adjacentDirs dir=%s", playbookRoot)
+	}
+	deps = append(deps, aDeps...)
+	hosts := []string{}
 	for _, play := range playbook {
+		hosts = append(hosts, play.Hosts...)
+		for _, name := range []string{play.ImportPlaybook, play.Include} {
+			if name == "" {
+				continue
+			}
+			// nested playbook is relative to the including one or from collection
+			pbPath, pErr := w.searchPlaybookPath(name, path.Dir(filePath), playbookRoot)
+			if pErr != nil {
+				return nil, errors.Wrapf(pErr, "searchPlaybookPath name=%s", name)
+			}
+			nested, pErr := w.parsePlaybook(pbPath, repoDir)
+			if pErr != nil {
+				return nil, errors.Wrapf(pErr, "parsePlaybook name=%s", name)
+			}
+			deps = append(deps, nested.Files...)
+			hosts = append(hosts, nested.Hosts...)
+		}
+		w.useCollections(play.Collections)
+		// handlers & notifications are scoped to play
+		w.handlers, w.notified = nil, map[string]bool{}
+		sc := scope{playbookRoot: playbookRoot, collections: play.Collections}
+		if coll, _ := collectionOf(playbookRoot); coll != "" {
+			// playbook of collection looks for its own content first
+			sc.collections = append([]string{coll}, play.Collections...)
+		}
+		sc.vars = mergeVars(map[string]interface{}{"playbook_dir": playbookRoot}, play.Vars)
+		// vars files & tasks of play level are relative to playbook dir
+		varsFiles := []string{}
+		for _, candidates := range play.VarsFiles {
+			for _, candidate := range candidates {
+				names, eErr := w.expandName(candidate, sc, []string{playbookRoot}, false)
+				if eErr != nil {
+					return nil, errors.Wrapf(eErr, "expandName vars_files=%s", candidate)
+				}
+				for _, name := range names {
+					if !path.IsAbs(name) {
+						name = path.Join(playbookRoot, name)
+					}
+					varsFiles = append(varsFiles, name)
+				}
+			}
+		}
+		fileVars, err := w.loadVars(varsFiles)
+		if err != nil {
+			return nil, errors.Wrap(err, "loadVars section=vars_files")
+		}
+		sc.vars = mergeVars(sc.vars, fileVars)
+		lDeps, err := w.parseFileLookups(varsFiles, sc)
+		if err != nil {
+			return nil, errors.Wrap(err, "parseFileLookups section=vars_files")
+		}
+		deps = append(deps, varsFiles...)
+		deps = append(deps, lDeps...)
+		parseSection := func(section string, taskList []Task) error {
+			tDeps, tErr := w.parseTaskList(taskList, sc)
+			if tErr != nil {
+				return errors.Wrapf(tErr, "parseTaskList section=%s", section)
+			}
+			deps = append(deps, tDeps...)
+			return nil
+		}
+		if err = parseSection("pre_tasks", play.PreTasks); err != nil {
+			return nil, err
+		}
 		for _, role := range play.Roles {
-			roleDeps, rErr := parseRole(role.Name, playbookRoot, ds)
+			// role params are visible to role only
+			rsc := sc
+			rsc.vars = mergeVars(sc.vars, role.Params, role.Vars)
+			roleDeps, rErr := w.parseRole(role.Name, role.EntryPoints, rsc)
 			if rErr != nil {
 				return nil, errors.Wrapf(rErr, "parseRole name=%s", role.Name)
 			}
 			deps = append(deps, roleDeps...)
 		}
-	}
-	log.Printf("Dependencies: %+v", deps)
-	return deps, nil
-}
-
-func parseRole(name string, playbookRoot string, ds loader.DataSource) ([]string, error) {
-	// log.Printf("Parse role '%s' root=%s", name, playbookRoot)
-	rPath, err := searchRolePath(name, playbookRoot, ds)
-	if err != nil {
-		return nil, errors.Wrapf(err, "searchRolePath name=%s", name)
-	}
-	// all files containing path prefix that matched
-	deps := []string{rPath}
-
-	// fetch all task includes/imports
-	taskRoot := path.Join(rPath, "tasks")
-	includeFiles, err := ds.ReadDir(taskRoot)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// no need explore more
-			return deps, nil
+		if err = parseSection("tasks", play.Tasks); err != nil {
+			return nil, err
 		}
-		return nil, errors.Wrapf(err, "ds.ReadDir dir_path=%s", taskRoot)
-	}
-	// fetch task file content
-	for _, incPath := range includeFiles {
-		tDeps, tErr := parseTask(incPath, taskRoot, ds)
-		if tErr != nil {
-			return nil, errors.Wrapf(tErr, "parseTask path=%s", incPath)
+		if err = parseSection("post_tasks", play.PostTasks); err != nil {
+			return nil, err
+		}
+		if err = w.parseHandlers(play.Handlers, "", sc); err != nil {
+			return nil, errors.Wrap(err, "parseHandlers section=handlers")
 		}
-		deps = append(deps, tDeps...)
+		// handlers count only when notified by tasks of play
+		deps = append(deps, w.notifiedHandlers()...)
 	}
-	return deps, nil
+	deps = uniq(deps)
+	log.Printf("Dependencies: %+v", deps)
+	return &Playbook{Files: deps, Hosts: uniq(hosts), Roles: uniq(w.roles), Collections: uniq(w.collections), Vaults: w.usedVaults(deps)}, nil
 }
 
-// looking for files from other than current root only
-func parseTask(name string, root string, ds loader.DataSource) ([]string, error) {
-	// log.Printf("Parse task '%s' root=%s", name, root)
-	filePath := path.Join(root, name)
-	deps := []string{}
-	baseDir := path.Dir(filePath)
-	if baseDir != root {
-		deps = append(deps, filePath)
-	}
-
-	content, err := ds.ReadFile(filePath)
-	if err != nil {
-		return nil, errors.Wrapf(err, "dataSource file_path=%s", filePath)
-	}
-	taskList := []Task{}
-	if err = yaml.Unmarshal(content, &taskList); err != nil {
-		return nil, errors.Wrapf(err, "yaml.Unmarshal file_path=%s", filePath)
-	}
+// adjacentDirNames lists dirs next to playbook which ansible loads without being
+// referred to: inventory vars & plugins other than modules & filters, which
+// are matched per task using them
+var adjacentDirNames = []string{
+	"group_vars",
+	"host_vars",
+	"action_plugins",
+	"become_plugins",
+	"cache_plugins",
+	"callback_plugins",
+	"cliconf_plugins",
+	"connection_plugins",
+	"httpapi_plugins",
+	"inventory_plugins",
+	"lookup_plugins",
+	"netconf_plugins",
+	"shell_plugins",
+	"strategy_plugins",
+	"terminal_plugins",
+	"test_plugins",
+	"vars_plugins",
+}
 
-	parseInclude := func(name string) error {
-		iDeps, iErr := parseTask(name, root, ds)
-		if iErr != nil {
-			return errors.Wrapf(iErr, "parseTask name=%s", name)
+// adjacentDirs returns existing dirs next to playbook which ansible loads
+// implicitly
+func (w *walker) adjacentDirs(playbookRoot string) ([]string, error) {
+	dirs := []string{}
+	for _, name := range adjacentDirNames {
+		p := path.Join(playbookRoot, name)
+		if exist, err := w.ds.IsExist(p); err != nil {
+			return nil, errors.Wrapf(err, "ds.IsExist path=%s", p)
+		} else if exist {
+			dirs = append(dirs, p)
 		}
-		deps = append(deps, iDeps...)
-		return nil
 	}
+	return dirs, nil
+}
 
-	for _, task := range taskList {
-		if task.IncludeTasks != "" {
-			if err = parseInclude(task.IncludeTasks); err != nil {
-				return nil, errors.Wrapf(err, "parseInclude include_tasks=%s", task.IncludeTasks)
-			}
-		}
-		if task.ImportTasks != "" {
-			if err = parseInclude(task.ImportTasks); err != nil {
-				return nil, errors.Wrapf(err, "parseInclude import_tasks=%s", task.ImportTasks)
-			}
-		}
-		if task.Include != "" {
-			if err = parseInclude(task.Include); err != nil {
-				return nil, errors.Wrapf(err, "parseInclude include=%s", task.Include)
-			}
+// useCollections records collections referenced by name, builtin ones are
+// shipped with ansible itself
+func (w *walker) useCollections(names []string) {
+	for _, v := range names {
+		if !builtinCollections[v] {
+			w.collections = append(w.collections, v)
 		}
 	}
-	return deps, nil
 }
 
-// role name could be directory path relative to playbook base dir `roles`,
-// or without `roles/` dir. note that paths from DEFAULT_ROLES_PATH are ignored
-func searchRolePath(name string, baseDir string, ds loader.DataSource) (string, error) {
-	searchPaths := []string{baseDir, path.Join(baseDir, "roles")}
-	for _, p := range searchPaths {
-		rPath := path.Join(p, name)
-		if exist, err := ds.IsExist(rPath); err != nil {
-			return "", errors.Wrapf(err, "ds.IsExist path=%s", rPath)
-		} else if exist {
-			return rPath, nil
+// uniq removes duplicated items while keeping their first-seen order
+func uniq(items []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, v := range items {
+		if seen[v] {
+			continue
 		}
+		seen[v] = true
+		out = append(out, v)
 	}
-	return "", errors.Errorf("role %s was not found in %+v", name, searchPaths)
+	return out
 }
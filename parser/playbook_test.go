@@ -13,11 +13,14 @@ import (
 func TestParsePlaybook(t *testing.T) {
 	ds := new(loader.MemoryLoader)
 	for _, c := range []struct {
-		caseName string
-		playbook string
-		setup    func()
-		err      bool
-		want     []string
+		caseName    string
+		playbook    string
+		setup       func()
+		err         bool
+		want        []string
+		hosts       []string
+		roles       []string
+		collections []string
 	}{
 		{
 			caseName: "playbook_with_empty_role",
@@ -28,7 +31,7 @@ func TestParsePlaybook(t *testing.T) {
   hosts: all`))
 			},
 
-			want: []string{"."},
+			want: []string{"empty.yml"},
 		},
 		{
 			caseName: "playbook_with_single_role_explicit_path",
@@ -40,9 +43,9 @@ func TestParsePlaybook(t *testing.T) {
   roles:
   - role: roles/r1
 `))
-				ds.SetFile("roles/r1", []byte(""))
+				ds.SetFile("roles/r1/tasks/main.yml", []byte(""))
 			},
-			want: []string{".", "roles/r1"},
+			want: []string{"single_role_explicit.yml", "roles/r1/tasks/main.yml"},
 		},
 		{
 			caseName: "playbook_with_single_role_implicit_path",
@@ -54,9 +57,9 @@ func TestParsePlaybook(t *testing.T) {
   roles:
   - role: r2
 `))
-				ds.SetFile("roles/r2", []byte(""))
+				ds.SetFile("roles/r2/tasks/main.yml", []byte(""))
 			},
-			want: []string{".", "roles/r2"},
+			want: []string{"single_role_implicit.yml", "roles/r2/tasks/main.yml"},
 		},
 		{
 			caseName: "playbook_with_multiple_roles",
@@ -69,311 +72,407 @@ func TestParsePlaybook(t *testing.T) {
   - role: r1
   - role: r2
 `))
-				ds.SetFile("roles/r1", []byte(""))
-				ds.SetFile("roles/r2", []byte(""))
+				ds.SetFile("roles/r1/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/r2/tasks/main.yml", []byte(""))
 			},
-			want: []string{".", "roles/r1", "roles/r2"},
+			want: []string{"multiple_roles.yml", "roles/r1/tasks/main.yml", "roles/r2/tasks/main.yml"},
 		},
 		{
-			caseName: "playbook_not_exist",
-			playbook: "not_exist.yml",
-			setup:    func() {},
-			err:      true,
-		},
-		{
-			caseName: "playbook_with_malformed_content",
-			playbook: "malformed.yml",
+			caseName: "playbook_with_all_role_syntaxes",
+			playbook: "role_syntaxes.yml",
 			setup: func() {
-				ds.SetFile("malformed.yml", []byte(`abcde`))
+				ds.SetFile("role_syntaxes.yml", []byte(`
+- hosts: all
+  roles:
+  - r1
+  - name: r2
+  - { role: r3, vars: { port: 80 }, tags: [web] }
+  - role: r4
+    when: ansible_os_family == 'Debian'
+    r4_port: 8080
+- hosts: all
+  roles: [r5]
+`))
+				for _, r := range []string{"r1", "r2", "r3", "r4", "r5"} {
+					ds.SetFile(fmt.Sprintf("roles/%s/tasks/main.yml", r), []byte(""))
+				}
+			},
+			want: []string{
+				"role_syntaxes.yml",
+				"roles/r1/tasks/main.yml",
+				"roles/r2/tasks/main.yml",
+				"roles/r3/tasks/main.yml",
+				"roles/r4/tasks/main.yml",
+				"roles/r5/tasks/main.yml",
 			},
-			err: true,
 		},
 		{
-			caseName: "playbook_with_roles_not_exist",
-			playbook: "roles_not_exist.yml",
+			caseName: "playbook_with_transitive_role_dependencies",
+			playbook: "transitive_roles.yml",
 			setup: func() {
-				ds.SetFile("roles_not_exist.yml", []byte(`
-- name: Test multiple roles
+				ds.SetFile("transitive_roles.yml", []byte(`
+- name: Test role dependencies
   hosts: all
   roles:
-  - role: r0
-  - role: r1
+  - role: app
 `))
-				ds.SetFile("roles/r1", []byte(""))
+				ds.SetFile("roles/app/meta/main.yml", []byte(`
+dependencies: [common, nginx]`))
+				ds.SetFile("roles/common/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/nginx/tasks/main.yml", []byte(""))
 			},
-			err: true,
+			want: []string{"transitive_roles.yml", "roles/app/meta/main.yml", "roles/common/tasks/main.yml", "roles/nginx/tasks/main.yml"},
 		},
-	} {
-		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
-			ds.Clear()
-			c.setup()
-			out, err := ParsePlaybook(c.playbook, "", ds)
-			if c.err == true {
-				assert.Error(t, err)
-			} else {
-				require.NoError(t, err)
-				assert.Equal(t, c.want, out)
-			}
-		})
-	}
-}
-
-func TestSearchRolePath(t *testing.T) {
-	ds := new(loader.MemoryLoader)
-	for _, c := range []struct {
-		caseName string
-		role     string
-		setup    func()
-		err      bool
-		want     string
-	}{
 		{
-			caseName: "explicitly_declared_within_roles_dir",
-			role:     "roles/test-role",
+			caseName: "playbook_with_import_playbook",
+			playbook: "site.yml",
 			setup: func() {
-				ds.SetFile("roles/test-role", []byte(""))
+				ds.SetFile("site.yml", []byte(`
+- import_playbook: webservers.yml
+- import_playbook: db/dbservers.yml`))
+				ds.SetFile("webservers.yml", []byte(`
+- hosts: webservers
+  roles:
+  - role: nginx`))
+				ds.SetFile("db/dbservers.yml", []byte(`
+- hosts: dbservers
+  roles:
+  - role: mysql`))
+				ds.SetFile("roles/nginx/tasks/main.yml", []byte(""))
+				ds.SetFile("db/roles/mysql/tasks/main.yml", []byte(""))
 			},
-			want: "roles/test-role",
+			want:  []string{"site.yml", "webservers.yml", "roles/nginx/tasks/main.yml", "db/dbservers.yml", "db/roles/mysql/tasks/main.yml"},
+			hosts: []string{"webservers", "dbservers"},
 		},
 		{
-			caseName: "implicitly_declared_within_roles_dir",
-			role:     "test-role",
+			caseName: "playbook_with_legacy_include",
+			playbook: "legacy.yml",
 			setup: func() {
-				ds.SetFile("roles/test-role", []byte(""))
+				ds.SetFile("legacy.yml", []byte(`
+- include: nested/child.yml
+- hosts: all
+  roles:
+  - role: r1`))
+				ds.SetFile("nested/child.yml", []byte(`
+- hosts: all
+  roles:
+  - role: r2`))
+				ds.SetFile("roles/r1/tasks/main.yml", []byte(""))
+				ds.SetFile("nested/roles/r2/tasks/main.yml", []byte(""))
 			},
-			want: "roles/test-role",
+			want: []string{"legacy.yml", "nested/child.yml", "nested/roles/r2/tasks/main.yml", "roles/r1/tasks/main.yml"},
 		},
 		{
-			caseName: "relative_path_to_base_dir",
-			role:     "../other/another-role",
+			caseName: "playbook_with_import_playbook_not_exist",
+			playbook: "import_not_exist.yml",
 			setup: func() {
-				ds.SetFile("other/another-role", []byte(""))
+				ds.SetFile("import_not_exist.yml", []byte(`
+- import_playbook: not_exist.yml`))
 			},
-			want: "other/another-role",
+			err: true,
 		},
 		{
-			caseName: "unexpected_error_when_check_role_exist",
-			role:     "must-raise-error",
+			caseName: "playbook_with_play_level_tasks",
+			playbook: "play_tasks.yml",
 			setup: func() {
-				ds.SetFile("must-raise-error", []byte("unexpected_error"))
+				ds.SetFile("play_tasks.yml", []byte(`
+- hosts: all
+  pre_tasks:
+  - import_tasks: ../shared/pre.yml
+  tasks:
+  - include_tasks: local.yml
+    notify: restart app
+  - include_role: name=r1
+  post_tasks:
+  - include_tasks: ../shared/post.yml
+  handlers:
+  - name: restart app
+    import_tasks: ../shared/handlers.yml`))
+				ds.SetFile("local.yml", []byte(""))
+				ds.SetFile("../shared/pre.yml", []byte(""))
+				ds.SetFile("../shared/post.yml", []byte(""))
+				ds.SetFile("../shared/handlers.yml", []byte(""))
+				ds.SetFile("roles/r1/tasks/main.yml", []byte(""))
 			},
-			err: true,
+			want: []string{"play_tasks.yml", "../shared/pre.yml", "local.yml", "roles/r1/tasks/main.yml", "../shared/post.yml", "../shared/handlers.yml"},
 		},
 		{
-			caseName: "error_role_not_exist",
-			role:     "role-not-exist",
-			setup:    func() {},
-			err:      true,
-		},
-	} {
-		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
-			ds.Clear()
-			c.setup()
-			out, err := searchRolePath(c.role, "", ds)
-			if c.err == true {
-				assert.Error(t, err)
-			} else {
-				require.NoError(t, err)
-				assert.Equal(t, c.want, out)
-			}
-		})
-	}
-}
-
-func TestParseTask(t *testing.T) {
-	ds := new(loader.MemoryLoader)
-	for _, c := range []struct {
-		caseName string
-		task     string
-		baseDir  string
-		setup    func()
-		err      bool
-		want     []string
-	}{
-		{
-			caseName: "file_with_no_includes",
-			task:     "no-includes.yml",
+			caseName: "playbook_with_blocks_in_handlers",
+			playbook: "handler_blocks.yml",
 			setup: func() {
-				ds.SetFile("no-includes.yml", []byte(`
-- name: Assert true is not false
-  assert:
-    that: 1 != 0`))
+				ds.SetFile("handler_blocks.yml", []byte(`
+- hosts: all
+  tasks:
+  - command: /bin/deploy
+    notify: restart app
+  handlers:
+  - name: restart app
+    block:
+    - include_tasks: ../shared/restart.yml
+    rescue:
+    - include_tasks: ../shared/rollback.yml`))
+				ds.SetFile("../shared/restart.yml", []byte(""))
+				ds.SetFile("../shared/rollback.yml", []byte(""))
 			},
-			want: []string{},
+			want: []string{"handler_blocks.yml", "../shared/restart.yml", "../shared/rollback.yml"},
 		},
 		{
-			caseName: "file_with_include_tasks_in_same_dir",
-			task:     "with-include_tasks.yml",
+			caseName: "playbook_with_play_level_tasks_malformed",
+			playbook: "play_tasks_malformed.yml",
 			setup: func() {
-				ds.SetFile("with-include_tasks.yml", []byte(`
-- name: Do something
-  include_tasks: something.yml`))
-				ds.SetFile("something.yml", []byte(``))
+				ds.SetFile("play_tasks_malformed.yml", []byte(`
+- hosts: all
+  tasks:
+  - include_tasks: ../shared/malformed.yml`))
+				ds.SetFile("../shared/malformed.yml", []byte("abcde"))
 			},
-			want: []string{},
+			err: true,
 		},
 		{
-			caseName: "file_with_include_tasks_in_relative_dir",
-			task:     "with-include_tasks-relative-dir.yml",
-			baseDir:  "/tmp/r1/tasks",
+			caseName: "playbook_with_vars_files",
+			playbook: "vars_files.yml",
 			setup: func() {
-				ds.SetFile("/tmp/r1/tasks/with-include_tasks-relative-dir.yml", []byte(`
-- name: Do more things
-  include_tasks: ../../morethings.yml`))
-				ds.SetFile("/tmp/morethings.yml", []byte(``))
+				ds.SetFile("vars_files.yml", []byte(`
+- hosts: all
+  vars_files:
+  - ../vars/common.yml
+  - - ../vars/prod.yml
+    - ../vars/default.yml`))
 			},
-			want: []string{"/tmp/morethings.yml"},
+			want: []string{"vars_files.yml", "../vars/common.yml", "../vars/prod.yml", "../vars/default.yml"},
 		},
 		{
-			caseName: "file_with_import_tasks",
-			task:     "with-import_tasks.yml",
-			baseDir:  "/tmp/r2/tasks",
+			caseName: "playbook_with_absolute_vars_files",
+			playbook: "abs_vars_files.yml",
 			setup: func() {
-				ds.SetFile("/tmp/r2/tasks/with-import_tasks.yml", []byte(`
-- name: Do import
-  import_tasks: ../../staticthing.yml`))
-				ds.SetFile("/tmp/staticthing.yml", []byte(``))
+				ds.SetFile("abs_vars_files.yml", []byte(`
+- hosts: all
+  vars_files:
+  - /etc/app/vars.yml
+  tasks:
+  - include_tasks: "tasks/{{ env }}.yml"`))
+				ds.SetFile("/etc/app/vars.yml", []byte(`env: prod`))
+				ds.SetFile("tasks/prod.yml", []byte(""))
+				ds.SetFile("tasks/dev.yml", []byte(""))
 			},
-			want: []string{"/tmp/staticthing.yml"},
+			want: []string{"abs_vars_files.yml", "/etc/app/vars.yml", "tasks/prod.yml"},
 		},
 		{
-			caseName: "file_with_depricated_include",
-			task:     "with-depricated_include.yml",
-			baseDir:  "/tmp/r3/tasks",
+			caseName: "playbook_with_templated_includes",
+			playbook: "templated.yml",
 			setup: func() {
-				ds.SetFile("/tmp/r3/tasks/with-depricated_include.yml", []byte(`
-- name: Do depricated include
-  include: ../../depricatedthing.yml`))
-				ds.SetFile("/tmp/depricatedthing.yml", []byte(``))
+				ds.SetFile("templated.yml", []byte(`
+- hosts: all
+  vars:
+    env: prod
+  vars_files:
+  - vars/common.yml
+  roles:
+  - role: "{{ app_role }}"
+  tasks:
+  - include_tasks: "tasks/{{ env }}.yml"
+  - include_tasks: "tasks/setup_{{ ansible_os_family | lower }}.yml"`))
+				ds.SetFile("vars/common.yml", []byte(`app_role: web`))
+				ds.SetFile("roles/web/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/db/tasks/main.yml", []byte(""))
+				ds.SetFile("tasks/prod.yml", []byte(""))
+				ds.SetFile("tasks/dev.yml", []byte(""))
+				ds.SetFile("tasks/setup_debian.yml", []byte(""))
+				ds.SetFile("tasks/setup_redhat.yml", []byte(""))
 			},
-			want: []string{"/tmp/depricatedthing.yml"},
-		},
-		{
-			caseName: "task_not_exist",
-			task:     "task_not_exist.yml",
-			setup:    func() {},
-			err:      true,
+			want: []string{
+				"templated.yml",
+				"vars/common.yml",
+				"roles/web/tasks/main.yml",
+				"tasks/prod.yml",
+				"tasks/setup_debian.yml",
+				"tasks/setup_redhat.yml",
+			},
+			roles: []string{"web"},
 		},
 		{
-			caseName: "task_file_content_malformed",
-			task:     "malformed.yml",
-			baseDir:  "/tmp/r4/tasks",
+			caseName: "playbook_with_dirs_loaded_next_to_it",
+			playbook: "adjacent/site.yml",
 			setup: func() {
-				ds.SetFile("/tmp/r4/tasks/malformed.yml", []byte(`abcde`))
+				ds.SetFile("adjacent/site.yml", []byte(`
+- hosts: all`))
+				ds.SetFile("adjacent/group_vars/all.yml", []byte(""))
+				ds.SetFile("adjacent/host_vars/web01.yml", []byte(""))
+				ds.SetFile("adjacent/lookup_plugins/vault_secret.py", []byte(""))
+				ds.SetFile("adjacent/README.md", []byte(""))
+			},
+			want: []string{
+				"adjacent/site.yml",
+				"adjacent/group_vars",
+				"adjacent/host_vars",
+				"adjacent/lookup_plugins",
 			},
-			err: true,
 		},
 		{
-			caseName: "include_tasks_content_malformed",
-			task:     "include_tasks_malformed.yml",
-			baseDir:  "/tmp/r5/tasks",
+			caseName: "playbook_with_globbed_include_among_other_yaml",
+			playbook: "play_glob/site.yml",
 			setup: func() {
-				ds.SetFile("/tmp/r5/tasks/include_tasks_malformed.yml", []byte(`
-- name: Do include tasks with malformed content
-  include_tasks: ../../malformed.yml`))
-				ds.SetFile("/tmp/malformed.yml", []byte(`abcde`))
+				ds.SetFile("play_glob/site.yml", []byte(`
+- hosts: all
+  tasks:
+  - include_tasks: "{{ os }}.yml"`))
+				ds.SetFile("play_glob/debian.yml", []byte(`
+- debug: msg=debian`))
+				ds.SetFile("play_glob/db.yml", []byte(`
+- hosts: dbservers`))
+				ds.SetFile("play_glob/docker-compose.yml", []byte(`
+services:
+  db:
+    image: postgres`))
+				ds.SetFile("play_glob/requirements.yml", []byte(`
+roles:
+- name: geerlingguy.java`))
 			},
-			err: true,
+			want: []string{"play_glob/site.yml", "play_glob/debian.yml"},
 		},
 		{
-			caseName: "import_tasks_content_malformed",
-			task:     "import_tasks_malformed.yml",
-			baseDir:  "/tmp/r7/tasks",
+			caseName: "playbook_with_fully_qualified_keywords",
+			playbook: "fqcn.yml",
 			setup: func() {
-				ds.SetFile("/tmp/r7/tasks/import_tasks_malformed.yml", []byte(`
-- name: Do import tasks with malformed content
-  import_tasks: ../../malformed.yml`))
-				ds.SetFile("/tmp/malformed.yml", []byte(`abcde`))
+				ds.SetFile("fqcn.yml", []byte(`
+- ansible.builtin.import_playbook: fqcn_nested.yml
+- hosts: all
+  tasks:
+  - ansible.builtin.include_tasks: tasks/a.yml
+  - ansible.legacy.import_tasks: tasks/b.yml
+  - ansible.builtin.include_vars: vars/main.yml
+  - ansible.builtin.include_role:
+      name: r1
+  - ansible.builtin.import_role:
+      name: r2`))
+				ds.SetFile("fqcn_nested.yml", []byte(`
+- hosts: all`))
+				ds.SetFile("tasks/a.yml", []byte(""))
+				ds.SetFile("tasks/b.yml", []byte(""))
+				ds.SetFile("vars/main.yml", []byte(""))
+				ds.SetFile("roles/r1/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/r2/tasks/main.yml", []byte(""))
+			},
+			want: []string{
+				"fqcn.yml",
+				"fqcn_nested.yml",
+				"tasks/a.yml",
+				"tasks/b.yml",
+				"vars/main.yml",
+				"roles/r1/tasks/main.yml",
+				"roles/r2/tasks/main.yml",
 			},
-			err: true,
 		},
 		{
-			caseName: "depricated_include_content_malformed",
-			task:     "depricated_include_malformed.yml",
-			baseDir:  "/tmp/r8/tasks",
+			caseName: "playbook_with_hosts_patterns",
+			playbook: "hosts.yml",
 			setup: func() {
-				ds.SetFile("/tmp/r8/tasks/depricated_include_malformed.yml", []byte(`
-- name: Do depricated include with malformed content
-  include: ../../malformed.yml`))
-				ds.SetFile("/tmp/malformed.yml", []byte(`abcde`))
+				ds.SetFile("hosts.yml", []byte(`
+- hosts: webservers:&prod
+- hosts: [db, cache]
+- hosts: webservers:&prod`))
 			},
-			err: true,
+			want:  []string{"hosts.yml"},
+			hosts: []string{"webservers:&prod", "db", "cache"},
 		},
-	} {
-		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
-			ds.Clear()
-			c.setup()
-			root := c.baseDir
-			if root == "" {
-				root = "."
-			}
-			out, err := parseTask(c.task, root, ds)
-			if c.err == true {
-				assert.Error(t, err)
-			} else {
-				require.NoError(t, err)
-				assert.Equal(t, c.want, out)
-			}
-		})
-	}
-}
-
-func TestParseRole(t *testing.T) {
-	ds := new(loader.MemoryLoader)
-	for _, c := range []struct {
-		caseName string
-		role     string
-		setup    func()
-		err      bool
-		want     []string
-	}{
 		{
-			caseName: "role_with_empty_tasks",
-			role:     "empty",
+			caseName: "playbook_with_collection_roles",
+			playbook: "collection_roles.yml",
 			setup: func() {
-				ds.SetFile("roles/empty", []byte(""))
+				ds.SetFile("collection_roles.yml", []byte(`
+- hosts: all
+  collections:
+  - ourorg.platform
+  roles:
+  - community.mysql.server
+  - nginx
+  tasks:
+  - include_role:
+      name: backup`))
+				ds.SetFile("collections/ansible_collections/community/mysql/roles/server/tasks/main.yml", []byte(""))
+				ds.SetFile("collections/ansible_collections/ourorg/platform/roles/nginx/meta/main.yml", []byte(`
+dependencies: [common]`))
+				ds.SetFile("collections/ansible_collections/ourorg/platform/roles/common/tasks/main.yml", []byte(""))
+				ds.SetFile("collections/ansible_collections/ourorg/platform/roles/backup/tasks/main.yml", []byte(""))
 			},
-			want: []string{"roles/empty"},
+			want: []string{
+				"collection_roles.yml",
+				"collections/ansible_collections/community/mysql/roles/server/tasks/main.yml",
+				"collections/ansible_collections/ourorg/platform/roles/nginx/meta/main.yml",
+				"collections/ansible_collections/ourorg/platform/roles/common/tasks/main.yml",
+				"collections/ansible_collections/ourorg/platform/roles/backup/tasks/main.yml",
+			},
+			roles:       []string{},
+			collections: []string{"ourorg.platform", "community.mysql"},
 		},
 		{
-			caseName: "role_with_multiple_tasks",
-			role:     "multiple-tasks",
+			caseName: "playbook_with_galaxy_roles",
+			playbook: "galaxy.yml",
 			setup: func() {
-				ds.SetFile("roles/multiple-tasks/tasks/t1.yml", []byte(""))
-				ds.SetFile("roles/multiple-tasks/tasks/t2.yml", []byte(""))
+				ds.SetFile("galaxy.yml", []byte(`
+- hosts: all
+  collections:
+  - ansible.builtin
+  roles:
+  - geerlingguy.java
+  - roles/app`))
+				ds.SetFile("roles/geerlingguy.java/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/app/meta/main.yml", []byte(`
+collections: [community.general]
+dependencies: [geerlingguy.java]`))
 			},
-			want: []string{"roles/multiple-tasks"},
+			want: []string{
+				"galaxy.yml",
+				"roles/geerlingguy.java/tasks/main.yml",
+				"roles/app/meta/main.yml",
+			},
+			roles:       []string{"geerlingguy.java", "app"},
+			collections: []string{"community.general"},
 		},
 		{
-			caseName: "role_with_include_tasks",
-			role:     "include-tasks",
+			caseName: "playbook_with_collection_playbook",
+			playbook: "collection_playbook.yml",
 			setup: func() {
-				ds.SetFile("test/something.yml", []byte(""))
-				ds.SetFile("roles/include-tasks/tasks/t1.yml", []byte(`
-- name: Do include tasks
-  include: ../../../test/something.yml`))
+				ds.SetFile("collection_playbook.yml", []byte(`
+- import_playbook: ourorg.platform.deploy`))
+				ds.SetFile("collections/ansible_collections/ourorg/platform/playbooks/deploy.yml", []byte(`
+- hosts: all
+  roles:
+  - app`))
+				ds.SetFile("collections/ansible_collections/ourorg/platform/roles/app/tasks/main.yml", []byte(""))
+			},
+			want: []string{
+				"collection_playbook.yml",
+				"collections/ansible_collections/ourorg/platform/playbooks/deploy.yml",
+				"collections/ansible_collections/ourorg/platform/roles/app/tasks/main.yml",
 			},
-			want: []string{"roles/include-tasks", "test/something.yml"},
 		},
 		{
-			caseName: "role_with_path_not_exist",
-			role:     "role-path-not-exist",
+			caseName: "playbook_not_exist",
+			playbook: "not_exist.yml",
 			setup:    func() {},
 			err:      true,
 		},
 		{
-			caseName: "unexpected_error_when_ReadDir",
-			role:     "unexpected-error",
+			caseName: "playbook_with_malformed_content",
+			playbook: "malformed.yml",
 			setup: func() {
-				ds.SetFile("roles/unexpected-error", []byte(`unexpected_error`))
+				ds.SetFile("malformed.yml", []byte(`abcde`))
 			},
 			err: true,
 		},
 		{
-			caseName: "role_with_malformed_task_content",
-			role:     "malformed-task",
+			caseName: "playbook_with_roles_not_exist",
+			playbook: "roles_not_exist.yml",
 			setup: func() {
-				ds.SetFile("roles/malformed-tasks/tasks/malformed.yml", []byte(`abcde`))
+				ds.SetFile("roles_not_exist.yml", []byte(`
+- name: Test multiple roles
+  hosts: all
+  roles:
+  - role: r0
+  - role: r1
+`))
+				ds.SetFile("roles/r1/tasks/main.yml", []byte(""))
 			},
 			err: true,
 		},
@@ -381,12 +480,21 @@ func TestParseRole(t *testing.T) {
 		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
 			ds.Clear()
 			c.setup()
-			out, err := parseRole(c.role, "", ds)
+			out, err := ParsePlaybook(c.playbook, "", nil, ds)
 			if c.err == true {
 				assert.Error(t, err)
 			} else {
 				require.NoError(t, err)
-				assert.Equal(t, c.want, out)
+				assert.Equal(t, c.want, out.Files)
+				if c.hosts != nil {
+					assert.Equal(t, c.hosts, out.Hosts)
+				}
+				if c.roles != nil {
+					assert.Equal(t, c.roles, out.Roles)
+				}
+				if c.collections != nil {
+					assert.Equal(t, c.collections, out.Collections)
+				}
 			}
 		})
 	}
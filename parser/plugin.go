@@ -0,0 +1,342 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// plugin dirs of roles & playbook
+const (
+	libraryDir       = "library"
+	filterPluginsDir = "filter_plugins"
+)
+
+// collection plugin dirs matching kind
+var collectionPluginDirs = map[string]string{
+	libraryDir:       "plugins/modules",
+	filterPluginsDir: "plugins/filter",
+}
+
+// keywords of task, any other key names module called by task
+var taskKeywords = map[string]bool{
+	"name":               true,
+	"action":             true,
+	"local_action":       true,
+	"args":               true,
+	"async":              true,
+	"poll":               true,
+	"become":             true,
+	"become_exe":         true,
+	"become_flags":       true,
+	"become_method":      true,
+	"become_user":        true,
+	"changed_when":       true,
+	"check_mode":         true,
+	"collections":        true,
+	"connection":         true,
+	"debugger":           true,
+	"delay":              true,
+	"delegate_facts":     true,
+	"delegate_to":        true,
+	"diff":               true,
+	"environment":        true,
+	"failed_when":        true,
+	"ignore_errors":      true,
+	"ignore_unreachable": true,
+	"listen":             true,
+	"loop":               true,
+	"loop_control":       true,
+	"module_defaults":    true,
+	"no_log":             true,
+	"notify":             true,
+	"port":               true,
+	"register":           true,
+	"remote_user":        true,
+	"retries":            true,
+	"run_once":           true,
+	"tags":               true,
+	"throttle":           true,
+	"timeout":            true,
+	"until":              true,
+	"vars":               true,
+	"when":               true,
+	"block":              true,
+	"rescue":             true,
+	"always":             true,
+	"include_tasks":      true,
+	"import_tasks":       true,
+	"include":            true,
+	"include_role":       true,
+	"import_role":        true,
+	"include_vars":       true,
+}
+
+// keywords holding bare jinja expressions without `{{ }}`
+var conditionalKeywords = map[string]bool{
+	"when":         true,
+	"changed_when": true,
+	"failed_when":  true,
+	"until":        true,
+}
+
+var (
+	jinjaPattern  = regexp.MustCompile(`(?s)\{\{(.*?)\}\}|\{%(.*?)%\}`)
+	filterPattern = regexp.MustCompile(`\|\s*([a-zA-Z_][a-zA-Z0-9_.]*)`)
+	// keys of dict returned by `FilterModule.filters`
+	filterNamePattern = regexp.MustCompile(`['"]([a-zA-Z_][a-zA-Z0-9_]*)['"]\s*:`)
+)
+
+// moduleOf returns name of module called by task fields
+func moduleOf(fields map[string]interface{}) string {
+	for _, k := range []string{"action", "local_action"} {
+		switch v := fields[k].(type) {
+		case string:
+			if comps := strings.Fields(v); len(comps) > 0 {
+				return comps[0]
+			}
+		case map[interface{}]interface{}:
+			if name, ok := v["module"].(string); ok {
+				return name
+			}
+		}
+	}
+	keys := []string{}
+	for k := range fields {
+		if taskKeywords[k] || strings.HasPrefix(k, "with_") {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return keys[0]
+}
+
+// argsOf returns scalar arguments given to module, either free-form or
+// mapping, merged with `args` keyword
+func argsOf(fields map[string]interface{}, module string) map[string]string {
+	args := map[string]string{}
+	merge := func(v interface{}) {
+		switch val := v.(type) {
+		case string:
+			for k, a := range parseArgs(val) {
+				args[k] = a
+			}
+		case map[interface{}]interface{}:
+			for k, a := range val {
+				key, ok := k.(string)
+				if !ok {
+					continue
+				}
+				switch a.(type) {
+				case string, bool, int, float64:
+					args[key] = fmt.Sprint(a)
+				}
+			}
+		}
+	}
+	action := false
+	for _, k := range []string{"action", "local_action"} {
+		switch v := fields[k].(type) {
+		case string:
+			if comps := strings.Fields(v); len(comps) > 0 {
+				merge(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(v), comps[0])))
+				action = true
+			}
+		case map[interface{}]interface{}:
+			merge(v)
+			delete(args, "module")
+			action = true
+		}
+	}
+	if !action && module != "" {
+		merge(fields[module])
+	}
+	merge(fields["args"])
+	return args
+}
+
+// filtersOf returns names of filters used in jinja expressions of task
+// fields, nested task groups are left to their own tasks
+func filtersOf(fields map[string]interface{}) []string {
+	filters := []string{}
+	var walk func(v interface{}, bare bool)
+	walk = func(v interface{}, bare bool) {
+		switch val := v.(type) {
+		case string:
+			exprs := []string{}
+			if bare {
+				exprs = append(exprs, val)
+			} else {
+				for _, m := range jinjaPattern.FindAllStringSubmatch(val, -1) {
+					exprs = append(exprs, m[1]+m[2])
+				}
+			}
+			for _, expr := range exprs {
+				for _, m := range filterPattern.FindAllStringSubmatch(expr, -1) {
+					filters = append(filters, m[1])
+				}
+			}
+		case []interface{}:
+			for _, item := range val {
+				walk(item, bare)
+			}
+		case map[interface{}]interface{}:
+			for _, item := range val {
+				walk(item, false)
+			}
+		}
+	}
+	for k, v := range fields {
+		switch k {
+		case "block", "rescue", "always":
+			continue
+		}
+		walk(v, conditionalKeywords[k])
+	}
+	sort.Strings(filters)
+	return uniq(filters)
+}
+
+// pluginDirs lists dirs where plugins of kind are searched for: current
+// role, roles loaded so far, playbook dir then configured paths
+func (w *walker) pluginDirs(kind string, sc scope) []string {
+	roots := []string{}
+	if sc.rolePath != "" {
+		roots = append(roots, sc.rolePath)
+	}
+	roots = append(roots, w.rolePaths...)
+	roots = append(roots, sc.playbookRoot)
+	dirs := []string{}
+	for _, r := range roots {
+		dirs = append(dirs, path.Join(r, kind))
+	}
+	switch kind {
+	case libraryDir:
+		dirs = append(dirs, w.cfg.Library...)
+	case filterPluginsDir:
+		dirs = append(dirs, w.cfg.FilterPlugins...)
+	case moduleUtilsDir:
+		dirs = append(dirs, w.cfg.ModuleUtils...)
+	}
+	return uniq(dirs)
+}
+
+// pluginIndex returns plugin name to file mapping of dir, cached by walker
+func (w *walker) pluginIndex(kind string, dir string) (map[string]string, error) {
+	key := kind + ":" + dir
+	if index, ok := w.plugins[key]; ok {
+		return index, nil
+	}
+	index := map[string]string{}
+	entries, err := w.ds.ReadDir(dir)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return nil, errors.Wrapf(err, "ds.ReadDir dir_path=%s", dir)
+	}
+	sort.Strings(entries)
+	for _, entry := range entries {
+		if entry == "" || strings.HasPrefix(entry, "_") {
+			continue
+		}
+		p := path.Join(dir, entry)
+		if kind != filterPluginsDir {
+			name := strings.TrimSuffix(entry, path.Ext(entry))
+			if _, ok := index[name]; !ok {
+				index[name] = p
+			}
+			continue
+		}
+		if path.Ext(entry) != ".py" {
+			continue
+		}
+		content, rErr := w.ds.ReadFile(p)
+		if rErr != nil {
+			return nil, errors.Wrapf(rErr, "dataSource file_path=%s", p)
+		}
+		for _, m := range filterNamePattern.FindAllStringSubmatch(string(content), -1) {
+			if _, ok := index[m[1]]; !ok {
+				index[m[1]] = p
+			}
+		}
+	}
+	if w.plugins == nil {
+		w.plugins = map[string]map[string]string{}
+	}
+	w.plugins[key] = index
+	return index, nil
+}
+
+// findPlugin returns file of plugin within scope, empty if it is not
+// shipped locally e.g. builtin one
+func (w *walker) findPlugin(kind string, name string, sc scope) (string, error) {
+	// legacy collection resolves local plugins as short name does
+	name = strings.TrimPrefix(name, "ansible.legacy.")
+	dirs := w.pluginDirs(kind, sc)
+	if namespace, collection, resource, ok := splitFQCN(name); ok {
+		coll := namespace + "." + collection
+		if builtinCollections[coll] {
+			return "", nil
+		}
+		w.useCollections([]string{coll})
+		cPath, err := w.searchCollectionPath(coll, sc.playbookRoot)
+		if err != nil {
+			// collection installed outside of repository
+			return "", nil
+		}
+		dirs = []string{path.Join(cPath, collectionPluginDirs[kind])}
+		name = resource
+	}
+	for _, dir := range dirs {
+		index, err := w.pluginIndex(kind, dir)
+		if err != nil {
+			return "", errors.Wrapf(err, "pluginIndex kind=%s", kind)
+		}
+		if p, ok := index[name]; ok {
+			return p, nil
+		}
+	}
+	return "", nil
+}
+
+// parsePlugins returns files of module & filters used by task along with
+// module_utils they import
+func (w *walker) parsePlugins(task Task, sc scope) ([]string, error) {
+	plugins := []string{}
+	if task.Module != "" {
+		p, err := w.findPlugin(libraryDir, task.Module, sc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "findPlugin module=%s", task.Module)
+		}
+		if p != "" {
+			plugins = append(plugins, p)
+		}
+	}
+	for _, name := range task.Filters {
+		p, err := w.findPlugin(filterPluginsDir, name, sc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "findPlugin filter=%s", name)
+		}
+		if p != "" {
+			plugins = append(plugins, p)
+		}
+	}
+	deps := []string{}
+	seen := map[string]bool{}
+	for _, p := range plugins {
+		uDeps, err := w.parseModuleUtils(p, sc, seen)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parseModuleUtils file_path=%s", p)
+		}
+		deps = append(deps, p)
+		deps = append(deps, uDeps...)
+	}
+	return deps, nil
+}
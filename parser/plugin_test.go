@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestTaskUnmarshalYAML(t *testing.T) {
+	for _, c := range []struct {
+		caseName string
+		content  string
+		module   string
+		args     map[string]string
+		filters  []string
+	}{
+		{
+			caseName: "module_with_keywords",
+			content: `
+name: Register record
+our_dns:
+  name: "{{ inventory_hostname | to_fqdn }}"
+  ip: "{{ ansible_host }}"
+register: result
+when: zone | is_managed
+with_items: "{{ records | default([]) }}"`,
+			module:  "our_dns",
+			args:    map[string]string{"name": "{{ inventory_hostname | to_fqdn }}", "ip": "{{ ansible_host }}"},
+			filters: []string{"default", "is_managed", "to_fqdn"},
+		},
+		{
+			caseName: "free_form_action",
+			content: `
+action: our_dns name={{ item | lower }}`,
+			module:  "our_dns",
+			args:    map[string]string{"name": "{{ item | lower }}"},
+			filters: []string{"lower"},
+		},
+		{
+			caseName: "local_action_mapping",
+			content: `
+local_action:
+  module: community.general.slack
+  msg: done`,
+			module:  "community.general.slack",
+			args:    map[string]string{"msg": "done"},
+			filters: []string{},
+		},
+		{
+			caseName: "fully_qualified_include_is_not_module",
+			content: `
+ansible.builtin.include_tasks: "{{ item | basename }}"`,
+			module:  "",
+			filters: []string{"basename"},
+		},
+		{
+			caseName: "block_filters_left_to_nested_tasks",
+			content: `
+block:
+- debug: msg="{{ foo | bar }}"
+when: enabled | bool`,
+			module:  "",
+			filters: []string{"bool"},
+		},
+		{
+			caseName: "statement_and_fqcn_filter",
+			content: `
+copy:
+  content: "{% for x in items | ourorg.platform.sorted_hosts %}{{ x }}{% endfor %}"
+  dest: /tmp/hosts`,
+			module:  "copy",
+			filters: []string{"ourorg.platform.sorted_hosts"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			task := Task{}
+			require.NoError(t, yaml.Unmarshal([]byte(c.content), &task))
+			assert.Equal(t, c.module, task.Module)
+			if c.args != nil {
+				assert.Equal(t, c.args, task.Args)
+			}
+			assert.Equal(t, c.filters, task.Filters)
+		})
+	}
+}
+
+func TestParsePlugins(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		task     Task
+		sc       scope
+		library  []string
+		setup    func()
+		err      bool
+		want     []string
+	}{
+		{
+			caseName: "module_of_playbook_library",
+			task:     Task{Module: "our_dns"},
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/library/our_dns.py", []byte(""))
+				ds.SetFile("pb/library/other.py", []byte(""))
+			},
+			want: []string{"pb/library/our_dns.py"},
+		},
+		{
+			caseName: "module_of_role_library_first",
+			task:     Task{Module: "our_dns"},
+			sc:       scope{playbookRoot: "pb", rolePath: "pb/roles/dns"},
+			setup: func() {
+				ds.SetFile("pb/library/our_dns.py", []byte(""))
+				ds.SetFile("pb/roles/dns/library/our_dns.ps1", []byte(""))
+			},
+			want: []string{"pb/roles/dns/library/our_dns.ps1"},
+		},
+		{
+			caseName: "module_of_configured_library",
+			task:     Task{Module: "our_dns"},
+			sc:       scope{playbookRoot: "pb"},
+			library:  []string{"/opt/modules"},
+			setup: func() {
+				ds.SetFile("/opt/modules/our_dns.py", []byte(""))
+			},
+			want: []string{"/opt/modules/our_dns.py"},
+		},
+		{
+			caseName: "builtin_module",
+			task:     Task{Module: "ansible.builtin.copy"},
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/library/copy.py", []byte(""))
+			},
+			want: []string{},
+		},
+		{
+			caseName: "legacy_module",
+			task:     Task{Module: "ansible.legacy.our_dns"},
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/library/our_dns.py", []byte(""))
+			},
+			want: []string{"pb/library/our_dns.py"},
+		},
+		{
+			caseName: "collection_module",
+			task:     Task{Module: "ourorg.platform.our_dns"},
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/collections/ansible_collections/ourorg/platform/plugins/modules/our_dns.py", []byte(""))
+			},
+			want: []string{"pb/collections/ansible_collections/ourorg/platform/plugins/modules/our_dns.py"},
+		},
+		{
+			caseName: "collection_not_in_repo",
+			task:     Task{Module: "community.general.slack"},
+			sc:       scope{playbookRoot: "pb"},
+			setup:    func() {},
+			want:     []string{},
+		},
+		{
+			caseName: "filters_of_plugin_files",
+			task:     Task{Filters: []string{"default", "to_fqdn"}},
+			sc:       scope{playbookRoot: "pb", rolePath: "pb/roles/dns"},
+			setup: func() {
+				ds.SetFile("pb/roles/dns/filter_plugins/__init__.py", []byte(""))
+				ds.SetFile("pb/roles/dns/filter_plugins/net.py", []byte(`
+class FilterModule(object):
+    def filters(self):
+        return {
+            'to_fqdn': to_fqdn,
+            "to_ptr": to_ptr,
+        }`))
+				ds.SetFile("pb/filter_plugins/other.py", []byte(`
+class FilterModule(object):
+    def filters(self):
+        return {'to_fqdn': to_fqdn}`))
+			},
+			want: []string{"pb/roles/dns/filter_plugins/net.py"},
+		},
+		{
+			caseName: "unexpected_error",
+			task:     Task{Module: "our_dns"},
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/library", []byte("unexpected_error"))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			w := &walker{cfg: &Config{Library: c.library}, ds: ds}
+			out, err := w.parsePlugins(c.task, c.sc)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
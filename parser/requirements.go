@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Requirement is role or collection entry of galaxy requirements file
+type Requirement struct {
+	Name    string `yaml:"name"`
+	Src     string `yaml:"src"`
+	Version string `yaml:"version"`
+	Scm     string `yaml:"scm"`
+	Type    string `yaml:"type"`
+	Source  string `yaml:"source"`
+}
+
+// UnmarshalYAML accepts plain name, legacy `src,version,name` or mapping
+// syntax
+func (r *Requirement) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw string
+	if err := unmarshal(&raw); err == nil {
+		comps := strings.Split(raw, ",")
+		r.Src = strings.TrimSpace(comps[0])
+		if len(comps) > 1 {
+			r.Version = strings.TrimSpace(comps[1])
+		}
+		if len(comps) > 2 {
+			r.Name = strings.TrimSpace(comps[2])
+		}
+		return nil
+	}
+	type plain Requirement
+	return unmarshal((*plain)(r))
+}
+
+// roleName returns dir name of installed role, derived from source url when
+// name is not given as ansible-galaxy does
+func (r Requirement) roleName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	src := r.Src
+	if !strings.Contains(src, "://") && !strings.Contains(src, "@") {
+		return src
+	}
+	name := path.Base(strings.TrimSuffix(src, "/"))
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, suffix := range []string{".git", ".tar.gz"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	if i := strings.Index(name, ","); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// collectionName returns `namespace.collection` of requirement, falling back
+// to source for collections installed from url or path
+func (r Requirement) collectionName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Src
+}
+
+// Requirements lists roles & collections of galaxy requirements file
+type Requirements struct {
+	Roles       []Requirement `yaml:"roles"`
+	Collections []Requirement `yaml:"collections"`
+}
+
+// ParseRequirements decodes either legacy list of roles or mapping of roles
+// and collections
+func ParseRequirements(content []byte) (*Requirements, error) {
+	reqs := &Requirements{}
+	roles := []Requirement{}
+	if err := yaml.Unmarshal(content, &roles); err == nil {
+		reqs.Roles = roles
+		return reqs, nil
+	}
+	if err := yaml.Unmarshal(content, reqs); err != nil {
+		return nil, errors.Wrap(err, "yaml.Unmarshal")
+	}
+	return reqs, nil
+}
+
+// requirementsFiles lists galaxy requirements files of playbook dir
+var requirementsFiles = []string{
+	"roles/requirements.yml",
+	"roles/requirements.yaml",
+	"collections/requirements.yml",
+	"collections/requirements.yaml",
+	"requirements.yml",
+	"requirements.yaml",
+}
+
+// isRequiredRole reports whether role or collection of role given by name
+// is listed in galaxy requirements files of playbook dir
+func (w *walker) isRequiredRole(name string, sc scope) (bool, error) {
+	for _, f := range requirementsFiles {
+		p := path.Join(sc.playbookRoot, f)
+		if exist, err := w.ds.IsExist(p); err != nil {
+			return false, errors.Wrapf(err, "ds.IsExist path=%s", p)
+		} else if !exist {
+			continue
+		}
+		content, err := w.ds.ReadFile(p)
+		if err != nil {
+			return false, errors.Wrapf(err, "ds.ReadFile path=%s", p)
+		}
+		reqs, err := ParseRequirements(content)
+		if err != nil {
+			return false, errors.Wrapf(err, "ParseRequirements path=%s", p)
+		}
+		if namespace, collection, _, ok := splitFQCN(name); ok {
+			for _, r := range reqs.Collections {
+				if r.collectionName() == namespace+"."+collection {
+					return true, nil
+				}
+			}
+			continue
+		}
+		for _, r := range reqs.Roles {
+			if r.roleName() == name {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// DiffRequirements returns names of roles and collections which are added,
+// removed or modified between before & after requirements. Nil requirements
+// is treated as empty file
+func DiffRequirements(before, after *Requirements) ([]string, []string) {
+	if before == nil {
+		before = &Requirements{}
+	}
+	if after == nil {
+		after = &Requirements{}
+	}
+	diff := func(olds, news []Requirement, name func(Requirement) string) []string {
+		entries := map[string][]Requirement{}
+		for _, v := range olds {
+			entries[name(v)] = append(entries[name(v)], v)
+		}
+		changed := map[string]bool{}
+		for _, v := range news {
+			n := name(v)
+			found := false
+			for i, o := range entries[n] {
+				if o == v {
+					entries[n] = append(entries[n][:i], entries[n][i+1:]...)
+					found = true
+					break
+				}
+			}
+			if !found {
+				changed[n] = true
+			}
+		}
+		for n, left := range entries {
+			if len(left) > 0 {
+				changed[n] = true
+			}
+		}
+		out := []string{}
+		for n := range changed {
+			out = append(out, n)
+		}
+		sort.Strings(out)
+		return out
+	}
+	return diff(before.Roles, after.Roles, Requirement.roleName),
+		diff(before.Collections, after.Collections, Requirement.collectionName)
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequirements(t *testing.T) {
+	for _, c := range []struct {
+		caseName string
+		content  string
+		err      bool
+		want     *Requirements
+	}{
+		{
+			caseName: "legacy_roles_list",
+			content: `
+- src: geerlingguy.java
+  version: 1.9.0
+- https://github.com/bennojoy/nginx,v1.0,nginx_role
+- name: app
+  src: git+https://git.example.com/app.git`,
+			want: &Requirements{Roles: []Requirement{
+				{Src: "geerlingguy.java", Version: "1.9.0"},
+				{Src: "https://github.com/bennojoy/nginx", Version: "v1.0", Name: "nginx_role"},
+				{Name: "app", Src: "git+https://git.example.com/app.git"},
+			}},
+		},
+		{
+			caseName: "roles_and_collections",
+			content: `
+roles:
+- name: geerlingguy.java
+  version: 1.9.0
+collections:
+- community.general
+- name: community.mysql
+  version: ">=3.0.0"
+  source: https://galaxy.ansible.com`,
+			want: &Requirements{
+				Roles: []Requirement{{Name: "geerlingguy.java", Version: "1.9.0"}},
+				Collections: []Requirement{
+					{Src: "community.general"},
+					{Name: "community.mysql", Version: ">=3.0.0", Source: "https://galaxy.ansible.com"},
+				},
+			},
+		},
+		{
+			caseName: "empty_file",
+			content:  "",
+			want:     &Requirements{Roles: []Requirement{}},
+		},
+		{
+			caseName: "invalid_content",
+			content:  "roles: abc",
+			err:      true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			out, err := ParseRequirements([]byte(c.content))
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
+
+func TestRequirementRoleName(t *testing.T) {
+	for _, c := range []struct {
+		req  Requirement
+		want string
+	}{
+		{req: Requirement{Name: "app", Src: "https://git.example.com/app.git"}, want: "app"},
+		{req: Requirement{Src: "geerlingguy.java"}, want: "geerlingguy.java"},
+		{req: Requirement{Src: "https://github.com/bennojoy/nginx"}, want: "nginx"},
+		{req: Requirement{Src: "git+https://git.example.com/team/app.git"}, want: "app"},
+		{req: Requirement{Src: "git@git.example.com:team/app.git"}, want: "app"},
+		{req: Requirement{Src: "https://example.com/files/app.tar.gz"}, want: "app"},
+	} {
+		assert.Equal(t, c.want, c.req.roleName(), c.req.Src)
+	}
+}
+
+func TestDiffRequirements(t *testing.T) {
+	before := &Requirements{
+		Roles: []Requirement{
+			{Src: "geerlingguy.java", Version: "1.9.0"},
+			{Src: "geerlingguy.nginx", Version: "2.0.0"},
+			{Name: "app", Src: "https://git.example.com/app.git", Version: "v1"},
+			{Src: "removed.role"},
+		},
+		Collections: []Requirement{
+			{Src: "community.general"},
+			{Name: "community.mysql", Version: "3.0.0"},
+		},
+	}
+	after := &Requirements{
+		Roles: []Requirement{
+			{Src: "geerlingguy.java", Version: "1.10.0"},
+			{Src: "geerlingguy.nginx", Version: "2.0.0"},
+			{Name: "app", Src: "https://git.example.com/app.git", Version: "v1"},
+			{Src: "added.role"},
+		},
+		Collections: []Requirement{
+			{Src: "community.general"},
+			{Name: "community.mysql", Version: "3.1.0"},
+		},
+	}
+	roles, collections := DiffRequirements(before, after)
+	assert.Equal(t, []string{"added.role", "geerlingguy.java", "removed.role"}, roles)
+	assert.Equal(t, []string{"community.mysql"}, collections)
+
+	// new requirements file
+	roles, collections = DiffRequirements(nil, after)
+	assert.Equal(t, []string{"added.role", "app", "geerlingguy.java", "geerlingguy.nginx"}, roles)
+	assert.Equal(t, []string{"community.general", "community.mysql"}, collections)
+
+	// unchanged
+	roles, collections = DiffRequirements(before, before)
+	assert.Empty(t, roles)
+	assert.Empty(t, collections)
+}
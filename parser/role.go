@@ -0,0 +1,492 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EntryPoints selects files loaded from role's tasks, vars, defaults and
+// handlers dirs instead of their main file
+type EntryPoints struct {
+	TasksFrom    string `yaml:"tasks_from"`
+	VarsFrom     string `yaml:"vars_from"`
+	DefaultsFrom string `yaml:"defaults_from"`
+	HandlersFrom string `yaml:"handlers_from"`
+}
+
+// frame returns name of role loaded through entry points on include stack,
+// so that role may include itself through other entry points
+func (ep EntryPoints) frame(rPath string) string {
+	opts := []string{}
+	for _, o := range []struct {
+		key string
+		val string
+	}{
+		{key: "tasks_from", val: ep.TasksFrom},
+		{key: "vars_from", val: ep.VarsFrom},
+		{key: "defaults_from", val: ep.DefaultsFrom},
+		{key: "handlers_from", val: ep.HandlersFrom},
+	} {
+		if o.val != "" {
+			opts = append(opts, o.key+"="+o.val)
+		}
+	}
+	if len(opts) == 0 {
+		return rPath
+	}
+	return rPath + " (" + strings.Join(opts, ", ") + ")"
+}
+
+// Role may define tasks include/import
+type Role struct {
+	Name        string `yaml:"role"`
+	EntryPoints `yaml:",inline"`
+	Vars        map[string]interface{} `yaml:"vars"`
+	Tags        StringList             `yaml:"tags"`
+	When        StringList             `yaml:"when"`
+	// Params keeps role variables given inline with legacy syntax
+	Params map[string]interface{} `yaml:"-"`
+}
+
+// keywords accepted by role declaration, other keys are role params
+var roleKeywords = map[string]bool{
+	"role":             true,
+	"name":             true,
+	"tasks_from":       true,
+	"vars_from":        true,
+	"defaults_from":    true,
+	"handlers_from":    true,
+	"vars":             true,
+	"tags":             true,
+	"when":             true,
+	"become":           true,
+	"become_user":      true,
+	"become_method":    true,
+	"delegate_to":      true,
+	"environment":      true,
+	"ignore_errors":    true,
+	"no_log":           true,
+	"any_errors_fatal": true,
+	"connection":       true,
+	"public":           true,
+	"apply":            true,
+}
+
+// UnmarshalYAML accepts role declared as plain string or mapping with either
+// `role` or `name` key
+func (r *Role) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		r.Name = name
+		return nil
+	}
+	type plain Role
+	if err := unmarshal((*plain)(r)); err != nil {
+		return err
+	}
+	fields := map[string]interface{}{}
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+	if r.Name == "" {
+		r.Name, _ = fields["name"].(string)
+	}
+	for k, v := range fields {
+		if roleKeywords[k] {
+			continue
+		}
+		if r.Params == nil {
+			r.Params = map[string]interface{}{}
+		}
+		r.Params[k] = v
+	}
+	return nil
+}
+
+// RoleInclude is argument of include_role & import_role tasks
+type RoleInclude struct {
+	Name        string `yaml:"name"`
+	EntryPoints `yaml:",inline"`
+}
+
+// UnmarshalYAML accepts free-form `name=foo tasks_from=bar` or mapping syntax
+func (ri *RoleInclude) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw string
+	if err := unmarshal(&raw); err == nil {
+		args := parseArgs(raw)
+		ri.Name = args["name"]
+		ri.TasksFrom = args["tasks_from"]
+		ri.VarsFrom = args["vars_from"]
+		ri.DefaultsFrom = args["defaults_from"]
+		ri.HandlersFrom = args["handlers_from"]
+		return nil
+	}
+	type plain RoleInclude
+	return unmarshal((*plain)(ri))
+}
+
+// RoleMeta holds role dependencies declared in meta/main.yml
+type RoleMeta struct {
+	Dependencies []Role   `yaml:"dependencies"`
+	Collections  []string `yaml:"collections"`
+}
+
+// dirs of role narrowed down to files loaded through entry points, plugins
+// or sources used by tasks
+var entryPointDirs = map[string]bool{
+	"tasks":          true,
+	"meta":           true,
+	"vars":           true,
+	"defaults":       true,
+	"handlers":       true,
+	libraryDir:       true,
+	filterPluginsDir: true,
+	moduleUtilsDir:   true,
+	filesDir:         true,
+	templatesDir:     true,
+}
+
+// parseRole returns files loaded by role, searched within given scope.
+// Templated name unresolved from vars of scope expands to matching roles
+func (w *walker) parseRole(name string, ep EntryPoints, sc scope) ([]string, error) {
+	// log.Printf("Parse role '%s' scope=%+v", name, sc)
+	deps := []string{}
+	if isTemplated(name) {
+		names, err := w.expandName(name, sc, w.roleSearchDirs(sc), true)
+		if err != nil {
+			return nil, errors.Wrapf(err, "expandName name=%s", name)
+		}
+		for _, n := range names {
+			rDeps, rErr := w.parseRole(n, ep, sc)
+			if rErr != nil {
+				return nil, errors.Wrapf(rErr, "parseRole name=%s", n)
+			}
+			deps = append(deps, rDeps...)
+		}
+		return deps, nil
+	}
+	rPath, err := w.searchRolePath(name, sc)
+	if _, missing := errors.Cause(err).(*notFoundError); missing {
+		// galaxy roles are usually installed before running only
+		required, rErr := w.isRequiredRole(name, sc)
+		if rErr != nil {
+			return nil, errors.Wrapf(rErr, "isRequiredRole name=%s", name)
+		}
+		if required {
+			log.Printf("Role '%s' not installed, recorded from galaxy requirements", name)
+			w.useRole(name)
+			return deps, nil
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "searchRolePath name=%s", name)
+	}
+	if ok, eErr := w.enter(ep.frame(rPath)); !ok {
+		return nil, eErr
+	}
+	defer w.leave()
+	// role of collection looks for short names within its own collection
+	roleScope := scope{playbookRoot: sc.playbookRoot, rolePath: rPath, collections: sc.collections}
+	if coll, _ := collectionOf(rPath); coll != "" {
+		roleScope.collections = append([]string{coll}, sc.collections...)
+		w.useCollections([]string{coll})
+	} else {
+		w.roles = append(w.roles, path.Base(rPath))
+	}
+	roleVars := map[string]interface{}{"role_path": rPath}
+	roleScope.vars = mergeVars(sc.vars, roleVars)
+
+	w.rolePaths = append(w.rolePaths, rPath)
+
+	// entry points named after variables are expanded as other names
+	findEntryPoints := func(dir string, from string) ([]string, error) {
+		froms := []string{from}
+		if isTemplated(from) {
+			var eErr error
+			if froms, eErr = w.expandName(from, roleScope, []string{dir}, false); eErr != nil {
+				return nil, errors.Wrapf(eErr, "expandName from=%s", from)
+			}
+		}
+		names := []string{}
+		for _, f := range froms {
+			if ext := path.Ext(f); isTemplated(from) && ext != "" && !isTaskFile(f) && ext != ".json" {
+				// globbed names may hit other files of dir
+				continue
+			}
+			fName, fErr := w.findEntryPoint(dir, f)
+			if fErr != nil {
+				return nil, errors.Wrapf(fErr, "findEntryPoint dir=%s", dir)
+			}
+			if fName != "" {
+				names = append(names, fName)
+			}
+		}
+		return names, nil
+	}
+	entryFiles := map[string][]string{}
+	for _, dir := range []struct {
+		name string
+		from string
+	}{
+		{name: "defaults", from: ep.DefaultsFrom},
+		{name: "vars", from: ep.VarsFrom},
+		{name: "handlers", from: ep.HandlersFrom},
+	} {
+		names, fErr := findEntryPoints(path.Join(rPath, dir.name), dir.from)
+		if fErr != nil {
+			return nil, fErr
+		}
+		for _, n := range names {
+			entryFiles[dir.name] = append(entryFiles[dir.name], path.Join(rPath, dir.name, n))
+		}
+	}
+	// defaults have lowest precedence while role vars override inherited ones
+	defaults, err := w.loadVars(entryFiles["defaults"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "loadVars path=%s", rPath)
+	}
+	vars, err := w.loadVars(entryFiles["vars"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "loadVars path=%s", rPath)
+	}
+	roleScope.vars = mergeVars(defaults, sc.vars, vars, roleVars)
+
+	// roles declared as dependencies run before current role
+	metaDeps, err := w.parseRoleMeta(roleScope)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parseRoleMeta path=%s", rPath)
+	}
+	deps = append(deps, metaDeps...)
+
+	// other than entry point dirs, all files containing path prefix matched
+	entries, err := w.ds.ReadDir(rPath)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return nil, errors.Wrapf(err, "ds.ReadDir dir_path=%s", rPath)
+	}
+	for _, entry := range entries {
+		if entry == "" || entryPointDirs[entry] {
+			continue
+		}
+		deps = append(deps, path.Join(rPath, entry))
+	}
+	for _, dir := range []string{"defaults", "vars"} {
+		deps = append(deps, entryFiles[dir]...)
+		lDeps, lErr := w.parseFileLookups(entryFiles[dir], roleScope)
+		if lErr != nil {
+			return nil, errors.Wrapf(lErr, "parseFileLookups dir=%s", dir)
+		}
+		deps = append(deps, lDeps...)
+	}
+
+	// handlers become dependencies once notified within play
+	for _, f := range entryFiles["handlers"] {
+		if err = w.parseHandlerFile(f, roleScope); err != nil {
+			return nil, errors.Wrapf(err, "parseHandlerFile path=%s", f)
+		}
+	}
+
+	// fetch task includes/imports starting from entry point
+	taskRoot := path.Join(rPath, "tasks")
+	taskFiles, err := findEntryPoints(taskRoot, ep.TasksFrom)
+	if err != nil {
+		return nil, err
+	}
+	if len(taskFiles) == 0 && ep.TasksFrom == "" {
+		// without main entry point every task file may be included
+		if taskFiles, err = w.listTaskFiles(taskRoot); err != nil {
+			return nil, errors.Wrapf(err, "listTaskFiles path=%s", taskRoot)
+		}
+	}
+	for _, taskFile := range taskFiles {
+		tDeps, tErr := w.parseTask(taskFile, roleScope)
+		if tErr != nil {
+			return nil, errors.Wrapf(tErr, "parseTask path=%s", taskFile)
+		}
+		deps = append(deps, tDeps...)
+	}
+	return w.roleGranularity(rPath, deps), nil
+}
+
+// roleGranularity collapses files of role into its dir when configured,
+// files of other roles & playbook are kept
+func (w *walker) roleGranularity(rPath string, deps []string) []string {
+	if !w.cfg.RoleGranularity {
+		return deps
+	}
+	out := []string{rPath}
+	for _, d := range deps {
+		if d != rPath && !strings.HasPrefix(d, rPath+"/") {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// findEntryPoint returns name of file to be loaded from role dir. Missing
+// main file is allowed while explicitly requested one is not
+func (w *walker) findEntryPoint(dir string, from string) (string, error) {
+	name := from
+	if name == "" {
+		name = "main"
+	}
+	for _, ext := range []string{"", ".yml", ".yaml", ".json"} {
+		p := path.Join(dir, name+ext)
+		if exist, err := w.ds.IsExist(p); err != nil {
+			return "", errors.Wrapf(err, "ds.IsExist path=%s", p)
+		} else if exist {
+			return name + ext, nil
+		}
+	}
+	if from != "" {
+		return "", errors.Errorf("file %s was not found in %s", from, dir)
+	}
+	return "", nil
+}
+
+// listTaskFiles returns yaml files within tasks dir & its subdirs relative
+// to it, other files such as docs are skipped
+func (w *walker) listTaskFiles(taskRoot string) ([]string, error) {
+	if isDir, err := w.ds.IsDir(taskRoot); err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "ds.IsDir path=%s", taskRoot)
+	} else if !isDir {
+		return nil, nil
+	}
+	files, err := w.walkDir(taskRoot, 1, 0, isTaskFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "walkDir path=%s", taskRoot)
+	}
+	names := []string{}
+	for _, f := range files {
+		names = append(names, strings.TrimPrefix(f, taskRoot+"/"))
+	}
+	return names, nil
+}
+
+// parseRoleMeta returns meta files of role along with dependencies of roles
+// listed in meta/main.yml, sc is scope of role declaring them
+func (w *walker) parseRoleMeta(sc scope) ([]string, error) {
+	rPath := sc.rolePath
+	deps := []string{}
+	// argument specs are validated before role runs
+	for _, name := range []string{"argument_specs.yml", "argument_specs.yaml"} {
+		p := path.Join(rPath, "meta", name)
+		if exist, err := w.ds.IsExist(p); err != nil {
+			return nil, errors.Wrapf(err, "ds.IsExist path=%s", p)
+		} else if exist {
+			deps = append(deps, p)
+		}
+	}
+	var metaPath string
+	for _, name := range []string{"main.yml", "main.yaml"} {
+		p := path.Join(rPath, "meta", name)
+		if exist, err := w.ds.IsExist(p); err != nil {
+			return nil, errors.Wrapf(err, "ds.IsExist path=%s", p)
+		} else if exist {
+			metaPath = p
+			break
+		}
+	}
+	if metaPath == "" {
+		return deps, nil
+	}
+	deps = append(deps, metaPath)
+	content, err := w.ds.ReadFile(metaPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dataSource file_path=%s", metaPath)
+	}
+	meta := RoleMeta{}
+	if err = yaml.Unmarshal(content, &meta); err != nil {
+		return nil, errors.Wrapf(err, "yaml.Unmarshal file_path=%s", metaPath)
+	}
+	w.useCollections(meta.Collections)
+	depScope := sc
+	depScope.collections = append(append([]string{}, meta.Collections...), sc.collections...)
+	for _, dep := range meta.Dependencies {
+		if dep.Name == "" {
+			return nil, errors.Errorf("role dependency without name in %s", metaPath)
+		}
+		rDeps, rErr := w.parseRole(dep.Name, dep.EntryPoints, depScope)
+		if rErr != nil {
+			return nil, errors.Wrapf(rErr, "parseRole name=%s", dep.Name)
+		}
+		deps = append(deps, rDeps...)
+	}
+	return deps, nil
+}
+
+// role name could be fully qualified collection name, short name found in
+// collections of scope, or directory path relative to playbook base dir
+// `roles`, one of configured roles path or playbook base dir itself, same
+// order as ansible lookup
+func (w *walker) searchRolePath(name string, sc scope) (string, error) {
+	if namespace, collection, role, ok := splitFQCN(name); ok {
+		cPath, err := w.searchCollectionPath(namespace+"."+collection, sc.playbookRoot)
+		if err != nil {
+			return "", errors.Wrapf(err, "searchCollectionPath name=%s", name)
+		}
+		rPath := path.Join(cPath, "roles", role)
+		if exist, err := w.ds.IsExist(rPath); err != nil {
+			return "", errors.Wrapf(err, "ds.IsExist path=%s", rPath)
+		} else if !exist {
+			return "", &notFoundError{kind: "role", name: name, dirs: []string{cPath}}
+		}
+		return rPath, nil
+	}
+	if shortNamePattern.MatchString(name) {
+		for _, coll := range sc.collections {
+			if builtinCollections[coll] {
+				continue
+			}
+			if rPath, err := w.searchRolePath(coll+"."+name, sc); err == nil {
+				return rPath, nil
+			}
+		}
+	}
+	searchPaths := w.roleSearchDirs(sc)
+	for _, p := range searchPaths {
+		rPath := path.Join(p, name)
+		if exist, err := w.ds.IsExist(rPath); err != nil {
+			return "", errors.Wrapf(err, "ds.IsExist path=%s", rPath)
+		} else if exist {
+			return rPath, nil
+		}
+	}
+	return "", &notFoundError{kind: "role", name: name, dirs: searchPaths}
+}
+
+// useRole records role used by name, either `namespace.collection.role`
+// or dir name of role
+func (w *walker) useRole(name string) {
+	if namespace, collection, _, ok := splitFQCN(name); ok {
+		w.useCollections([]string{namespace + "." + collection})
+		return
+	}
+	w.roles = append(w.roles, name)
+}
+
+// notFoundError reports role or collection missing from every dir searched
+type notFoundError struct {
+	kind string
+	name string
+	dirs []string
+}
+
+func (e *notFoundError) Error() string {
+	return fmt.Sprintf("%s %s was not found in %+v", e.kind, e.name, e.dirs)
+}
+
+// roleSearchDirs lists dirs searched for roles by name outside of collections
+func (w *walker) roleSearchDirs(sc scope) []string {
+	dirs := []string{path.Join(sc.playbookRoot, "roles")}
+	dirs = append(dirs, w.cfg.RolesPath...)
+	return append(dirs, sc.playbookRoot)
+}
@@ -0,0 +1,588 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestParseRole(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		role     string
+		ep       EntryPoints
+		perRole  bool
+		setup    func()
+		err      bool
+		want     []string
+	}{
+		{
+			caseName: "role_with_empty_tasks",
+			role:     "empty",
+			setup: func() {
+				ds.SetFile("roles/empty", []byte(""))
+			},
+			want: []string{},
+		},
+		{
+			caseName: "role_with_multiple_tasks",
+			role:     "multiple-tasks",
+			setup: func() {
+				ds.SetFile("roles/multiple-tasks/tasks/main.yml", []byte(`
+- import_tasks: t1.yml`))
+				ds.SetFile("roles/multiple-tasks/tasks/t1.yml", []byte(""))
+				ds.SetFile("roles/multiple-tasks/tasks/t2.yml", []byte(""))
+			},
+			want: []string{"roles/multiple-tasks/tasks/main.yml", "roles/multiple-tasks/tasks/t1.yml"},
+		},
+		{
+			caseName: "role_without_main_tasks",
+			role:     "no-main",
+			setup: func() {
+				ds.SetFile("roles/no-main/tasks/t1.yml", []byte(""))
+				ds.SetFile("roles/no-main/tasks/README.md", []byte("# Tasks"))
+				ds.SetFile("roles/no-main/tasks/install/apt.yaml", []byte(`
+- include_tasks: t1.yml`))
+			},
+			want: []string{
+				"roles/no-main/tasks/install/apt.yaml",
+				"roles/no-main/tasks/t1.yml",
+				"roles/no-main/tasks/t1.yml",
+			},
+		},
+		{
+			caseName: "role_with_main_tasks_and_nested_dirs",
+			role:     "nested-tasks",
+			setup: func() {
+				ds.SetFile("roles/nested-tasks/tasks/main.yml", []byte(`
+- include_tasks: install/apt.yml`))
+				ds.SetFile("roles/nested-tasks/tasks/install/apt.yml", []byte(""))
+				ds.SetFile("roles/nested-tasks/tasks/install/yum.yml", []byte(""))
+				ds.SetFile("roles/nested-tasks/tasks/README.md", []byte("# Tasks"))
+			},
+			want: []string{
+				"roles/nested-tasks/tasks/main.yml",
+				"roles/nested-tasks/tasks/install/apt.yml",
+			},
+		},
+		{
+			caseName: "role_with_globbed_include_skipping_other_files",
+			role:     "globbed-include",
+			setup: func() {
+				ds.SetFile("roles/globbed-include/tasks/main.yml", []byte(`
+- include_tasks: "steps/{{ step }}"`))
+				ds.SetFile("roles/globbed-include/tasks/steps/README.md", []byte("# Steps"))
+				ds.SetFile("roles/globbed-include/tasks/steps/setup.yml", []byte(""))
+			},
+			want: []string{
+				"roles/globbed-include/tasks/main.yml",
+				"roles/globbed-include/tasks/steps/setup.yml",
+			},
+		},
+		{
+			caseName: "role_with_globbed_include_skipping_including_file",
+			role:     "os-include",
+			setup: func() {
+				ds.SetFile("roles/os-include/tasks/main.yml", []byte(`
+- include_tasks: "{{ ansible_os_family | lower }}.yml"`))
+				ds.SetFile("roles/os-include/tasks/debian.yml", []byte(""))
+				ds.SetFile("roles/os-include/tasks/redhat.yml", []byte(""))
+			},
+			want: []string{
+				"roles/os-include/tasks/main.yml",
+				"roles/os-include/tasks/debian.yml",
+				"roles/os-include/tasks/redhat.yml",
+			},
+		},
+		{
+			caseName: "role_with_include_tasks",
+			role:     "include-tasks",
+			setup: func() {
+				ds.SetFile("test/something.yml", []byte(""))
+				ds.SetFile("roles/include-tasks/tasks/main.yml", []byte(`
+- name: Do include tasks
+  include: ../../../test/something.yml`))
+			},
+			want: []string{"roles/include-tasks/tasks/main.yml", "test/something.yml"},
+		},
+		{
+			caseName: "role_with_main_entry_points",
+			role:     "main-entry",
+			setup: func() {
+				ds.SetFile("roles/main-entry/defaults/main.yml", []byte(""))
+				ds.SetFile("roles/main-entry/defaults/other.yml", []byte(""))
+				ds.SetFile("roles/main-entry/vars/main.yaml", []byte(""))
+				ds.SetFile("roles/main-entry/handlers/main.yml", []byte(`
+- name: restart app
+  service: name=app state=restarted`))
+				ds.SetFile("roles/main-entry/templates/app.conf.j2", []byte(""))
+				ds.SetFile("roles/main-entry/templates/unused.conf.j2", []byte(""))
+				ds.SetFile("roles/main-entry/tasks/main.yml", []byte(`
+- template: src=app.conf.j2 dest=/etc/app.conf
+  notify: restart app`))
+				ds.SetFile("roles/main-entry/tasks/unused.yml", []byte(""))
+			},
+			want: []string{
+				"roles/main-entry/defaults/main.yml",
+				"roles/main-entry/vars/main.yaml",
+				"roles/main-entry/tasks/main.yml",
+				"roles/main-entry/templates/app.conf.j2",
+				"roles/main-entry/handlers/main.yml",
+			},
+		},
+		{
+			caseName: "role_with_custom_entry_points",
+			role:     "custom-entry",
+			ep: EntryPoints{
+				TasksFrom:    "backup",
+				VarsFrom:     "prod.yml",
+				DefaultsFrom: "extra",
+				HandlersFrom: "backup",
+			},
+			setup: func() {
+				ds.SetFile("roles/custom-entry/defaults/main.yml", []byte(""))
+				ds.SetFile("roles/custom-entry/defaults/extra.yml", []byte(""))
+				ds.SetFile("roles/custom-entry/vars/prod.yml", []byte(""))
+				ds.SetFile("roles/custom-entry/handlers/backup.yml", []byte(`
+- name: rotate backups
+  listen: backup done
+  command: /usr/local/bin/rotate`))
+				ds.SetFile("roles/custom-entry/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/custom-entry/tasks/backup.yml", []byte(`
+- include_tasks: dump.yml`))
+				ds.SetFile("roles/custom-entry/tasks/dump.yml", []byte(`
+- command: /usr/local/bin/dump
+  notify: backup done`))
+			},
+			want: []string{
+				"roles/custom-entry/defaults/extra.yml",
+				"roles/custom-entry/vars/prod.yml",
+				"roles/custom-entry/tasks/backup.yml",
+				"roles/custom-entry/tasks/dump.yml",
+				"roles/custom-entry/handlers/backup.yml",
+			},
+		},
+		{
+			caseName: "role_with_templated_entry_point_from_defaults",
+			role:     "templated-entry",
+			ep:       EntryPoints{TasksFrom: "{{ setup_flavor }}"},
+			setup: func() {
+				ds.SetFile("roles/templated-entry/defaults/main.yml", []byte(`setup_flavor: minimal`))
+				ds.SetFile("roles/templated-entry/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/templated-entry/tasks/minimal.yml", []byte(""))
+				ds.SetFile("roles/templated-entry/tasks/full.yml", []byte(""))
+			},
+			want: []string{
+				"roles/templated-entry/defaults/main.yml",
+				"roles/templated-entry/tasks/minimal.yml",
+			},
+		},
+		{
+			caseName: "role_with_templated_entry_point_glob",
+			role:     "os-entry",
+			ep:       EntryPoints{TasksFrom: "setup_{{ ansible_os_family | lower }}"},
+			setup: func() {
+				ds.SetFile("roles/os-entry/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/os-entry/tasks/setup_debian.yml", []byte(""))
+				ds.SetFile("roles/os-entry/tasks/setup_redhat.yml", []byte(""))
+			},
+			want: []string{
+				"roles/os-entry/tasks/setup_debian.yml",
+				"roles/os-entry/tasks/setup_redhat.yml",
+			},
+		},
+		{
+			caseName: "role_with_templated_name_glob",
+			role:     "app_{{ tier }}",
+			setup: func() {
+				ds.SetFile("roles/app_web/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/app_db/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/common/tasks/main.yml", []byte(""))
+			},
+			want: []string{
+				"roles/app_db/tasks/main.yml",
+				"roles/app_web/tasks/main.yml",
+			},
+		},
+		{
+			caseName: "role_with_argument_specs",
+			role:     "specs",
+			setup: func() {
+				ds.SetFile("roles/specs/meta/argument_specs.yml", []byte(""))
+				ds.SetFile("roles/specs/meta/main.yml", []byte(`
+galaxy_info:
+  author: zeno`))
+				ds.SetFile("roles/specs/meta/.galaxy_install_info", []byte(""))
+				ds.SetFile("roles/specs/tasks/main.yml", []byte(""))
+			},
+			want: []string{
+				"roles/specs/meta/argument_specs.yml",
+				"roles/specs/meta/main.yml",
+				"roles/specs/tasks/main.yml",
+			},
+		},
+		{
+			caseName: "role_with_role_granularity",
+			role:     "coarse",
+			perRole:  true,
+			setup: func() {
+				ds.SetFile("roles/coarse/meta/main.yml", []byte(`
+dependencies: [common]`))
+				ds.SetFile("roles/coarse/defaults/main.yml", []byte(""))
+				ds.SetFile("roles/coarse/tasks/main.yml", []byte(`
+- include_tasks: t1.yml
+- include_tasks: ../../common/tasks/setup.yml`))
+				ds.SetFile("roles/coarse/tasks/t1.yml", []byte(""))
+				ds.SetFile("roles/common/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/common/tasks/setup.yml", []byte(""))
+			},
+			want: []string{
+				"roles/coarse",
+				"roles/common",
+				"roles/common/tasks/setup.yml",
+			},
+		},
+		{
+			caseName: "role_with_entry_point_not_exist",
+			role:     "missing-entry",
+			ep:       EntryPoints{TasksFrom: "not-exist"},
+			setup: func() {
+				ds.SetFile("roles/missing-entry/tasks/main.yml", []byte(""))
+			},
+			err: true,
+		},
+		{
+			caseName: "role_with_meta_dependencies",
+			role:     "app",
+			setup: func() {
+				ds.SetFile("roles/app/meta/main.yml", []byte(`
+galaxy_info:
+  author: zeno
+dependencies:
+- common
+- role: nginx
+  when: ansible_os_family == 'Debian'
+  nginx_port: 8080
+- name: monitoring
+  tasks_from: agent`))
+				ds.SetFile("roles/common/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/nginx/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/monitoring/tasks/agent.yml", []byte(""))
+			},
+			want: []string{
+				"roles/app/meta/main.yml",
+				"roles/common/tasks/main.yml",
+				"roles/nginx/tasks/main.yml",
+				"roles/monitoring/tasks/agent.yml",
+			},
+		},
+		{
+			caseName: "role_with_transitive_meta_dependencies",
+			role:     "web",
+			setup: func() {
+				ds.SetFile("roles/web/meta/main.yml", []byte(`
+dependencies: [nginx]`))
+				ds.SetFile("roles/nginx/meta/main.yml", []byte(`
+dependencies:
+- role: common`))
+				ds.SetFile("roles/common/tasks/main.yml", []byte(""))
+			},
+			want: []string{"roles/web/meta/main.yml", "roles/nginx/meta/main.yml", "roles/common/tasks/main.yml"},
+		},
+		{
+			caseName: "role_with_meta_dependency_not_exist",
+			role:     "broken-meta",
+			setup: func() {
+				ds.SetFile("roles/broken-meta/meta/main.yml", []byte(`
+dependencies: [role-not-exist]`))
+			},
+			err: true,
+		},
+		{
+			caseName: "role_with_malformed_meta",
+			role:     "malformed-meta",
+			setup: func() {
+				ds.SetFile("roles/malformed-meta/meta/main.yml", []byte(`abcde`))
+			},
+			err: true,
+		},
+		{
+			caseName: "role_not_installed_but_required",
+			role:     "geerlingguy.mysql",
+			setup: func() {
+				ds.SetFile("roles/requirements.yml", []byte(`
+- src: https://github.com/geerlingguy/ansible-role-mysql.git
+  name: geerlingguy.mysql`))
+			},
+			want: []string{},
+		},
+		{
+			caseName: "role_not_installed_nor_required",
+			role:     "geerlingguy.mysql",
+			setup: func() {
+				ds.SetFile("roles/requirements.yml", []byte(`
+- geerlingguy.java`))
+			},
+			err: true,
+		},
+		{
+			caseName: "role_with_path_not_exist",
+			role:     "role-path-not-exist",
+			setup:    func() {},
+			err:      true,
+		},
+		{
+			caseName: "unexpected_error_when_ReadDir",
+			role:     "unexpected-error",
+			setup: func() {
+				ds.SetFile("roles/unexpected-error", []byte(`unexpected_error`))
+			},
+			err: true,
+		},
+		{
+			caseName: "role_with_malformed_task_content",
+			role:     "malformed-tasks",
+			setup: func() {
+				ds.SetFile("roles/malformed-tasks/tasks/main.yml", []byte(`abcde`))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			w := &walker{cfg: &Config{RoleGranularity: c.perRole}, ds: ds}
+			out, err := w.parseRole(c.role, c.ep, scope{})
+			out = append(out, w.notifiedHandlers()...)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
+
+func TestRoleUnmarshalYAML(t *testing.T) {
+	for _, c := range []struct {
+		caseName string
+		content  string
+		err      bool
+		want     Role
+	}{
+		{
+			caseName: "plain_string",
+			content:  `common`,
+			want:     Role{Name: "common"},
+		},
+		{
+			caseName: "role_key",
+			content:  `{role: db, tasks_from: backup}`,
+			want:     Role{Name: "db", EntryPoints: EntryPoints{TasksFrom: "backup"}},
+		},
+		{
+			caseName: "name_key",
+			content:  `{name: db, vars_from: prod}`,
+			want:     Role{Name: "db", EntryPoints: EntryPoints{VarsFrom: "prod"}},
+		},
+		{
+			caseName: "role_with_extra_fields",
+			content: `
+role: nginx
+vars:
+  port: 80
+tags: [web, proxy]
+when: ansible_os_family == 'Debian'
+become: yes`,
+			want: Role{
+				Name: "nginx",
+				Vars: map[string]interface{}{"port": 80},
+				Tags: StringList{"web", "proxy"},
+				When: StringList{"ansible_os_family == 'Debian'"},
+			},
+		},
+		{
+			caseName: "role_with_legacy_params",
+			content:  `{role: nginx, tags: web, nginx_port: 8080}`,
+			want: Role{
+				Name:   "nginx",
+				Tags:   StringList{"web"},
+				Params: map[string]interface{}{"nginx_port": 8080},
+			},
+		},
+		{
+			caseName: "malformed",
+			content:  `[a, b]`,
+			err:      true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			out := Role{}
+			err := yaml.Unmarshal([]byte(c.content), &out)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
+
+func TestRoleIncludeUnmarshalYAML(t *testing.T) {
+	for _, c := range []struct {
+		caseName string
+		content  string
+		want     RoleInclude
+	}{
+		{
+			caseName: "free_form",
+			content:  `name=db tasks_from=backup handlers_from=backup`,
+			want:     RoleInclude{Name: "db", EntryPoints: EntryPoints{TasksFrom: "backup", HandlersFrom: "backup"}},
+		},
+		{
+			caseName: "mapping",
+			content:  `{name: db, defaults_from: replica}`,
+			want:     RoleInclude{Name: "db", EntryPoints: EntryPoints{DefaultsFrom: "replica"}},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			out := RoleInclude{}
+			require.NoError(t, yaml.Unmarshal([]byte(c.content), &out))
+			assert.Equal(t, c.want, out)
+		})
+	}
+}
+
+func TestSearchRolePath(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName    string
+		role        string
+		rolesPath   []string
+		collections []string
+		setup       func()
+		err         bool
+		want        string
+	}{
+		{
+			caseName: "explicitly_declared_within_roles_dir",
+			role:     "roles/test-role",
+			setup: func() {
+				ds.SetFile("roles/test-role", []byte(""))
+			},
+			want: "roles/test-role",
+		},
+		{
+			caseName: "implicitly_declared_within_roles_dir",
+			role:     "test-role",
+			setup: func() {
+				ds.SetFile("roles/test-role", []byte(""))
+			},
+			want: "roles/test-role",
+		},
+		{
+			caseName: "relative_path_to_base_dir",
+			role:     "../other/another-role",
+			setup: func() {
+				ds.SetFile("other/another-role", []byte(""))
+			},
+			want: "other/another-role",
+		},
+		{
+			caseName:  "playbook_roles_dir_before_roles_path",
+			role:      "common",
+			rolesPath: []string{"shared-roles"},
+			setup: func() {
+				ds.SetFile("roles/common", []byte(""))
+				ds.SetFile("shared-roles/common", []byte(""))
+			},
+			want: "roles/common",
+		},
+		{
+			caseName:  "roles_path_in_order",
+			role:      "nginx",
+			rolesPath: []string{"shared-roles", "vendor-roles"},
+			setup: func() {
+				ds.SetFile("vendor-roles/nginx", []byte(""))
+				ds.SetFile("nginx", []byte(""))
+			},
+			want: "vendor-roles/nginx",
+		},
+		{
+			caseName: "fully_qualified_collection_role",
+			role:     "community.mysql.server",
+			setup: func() {
+				ds.SetFile("collections/ansible_collections/community/mysql/roles/server/tasks/main.yml", []byte(""))
+			},
+			want: "collections/ansible_collections/community/mysql/roles/server",
+		},
+		{
+			caseName: "fully_qualified_role_not_exist",
+			role:     "community.mysql.client",
+			setup: func() {
+				ds.SetFile("collections/ansible_collections/community/mysql/roles/server/tasks/main.yml", []byte(""))
+			},
+			err: true,
+		},
+		{
+			caseName: "fully_qualified_collection_not_exist",
+			role:     "community.mysql.client",
+			setup:    func() {},
+			err:      true,
+		},
+		{
+			caseName:    "short_name_within_collections",
+			role:        "nginx",
+			collections: []string{"ansible.builtin", "community.general", "ourorg.platform"},
+			setup: func() {
+				ds.SetFile("collections/ansible_collections/ourorg/platform/roles/nginx/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/nginx", []byte(""))
+			},
+			want: "collections/ansible_collections/ourorg/platform/roles/nginx",
+		},
+		{
+			caseName:    "short_name_fallback_to_roles_dir",
+			role:        "nginx",
+			collections: []string{"ourorg.platform"},
+			setup: func() {
+				ds.SetFile("roles/nginx", []byte(""))
+			},
+			want: "roles/nginx",
+		},
+		{
+			caseName: "unexpected_error_when_check_role_exist",
+			role:     "must-raise-error",
+			setup: func() {
+				ds.SetFile("must-raise-error", []byte("unexpected_error"))
+			},
+			err: true,
+		},
+		{
+			caseName: "error_role_not_exist",
+			role:     "role-not-exist",
+			setup:    func() {},
+			err:      true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			w := &walker{cfg: &Config{RolesPath: c.rolesPath}, ds: ds}
+			out, err := w.searchRolePath(c.role, scope{collections: c.collections})
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
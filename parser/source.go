@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"log"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// dirs of roles & playbook holding files used by modules
+const (
+	filesDir     = "files"
+	templatesDir = "templates"
+)
+
+// fileModule describes module reading local file from one of its arguments
+type fileModule struct {
+	names []string
+	// args lists arguments holding file path, first one set is used
+	args []string
+	// dir is searched for relative path
+	dir string
+	// remoteSrc is default of `remote_src` argument
+	remoteSrc bool
+}
+
+// modules whose arguments point at files on controller
+var fileModules = []fileModule{
+	{names: []string{"template"}, args: []string{"src"}, dir: templatesDir},
+	{names: []string{"win_template", "ansible.windows.win_template"}, args: []string{"src"}, dir: templatesDir},
+	{names: []string{"copy"}, args: []string{"src"}, dir: filesDir},
+	{names: []string{"win_copy", "ansible.windows.win_copy"}, args: []string{"src"}, dir: filesDir},
+	{names: []string{"script"}, args: []string{"cmd", "_raw_params"}, dir: filesDir},
+	{names: []string{"unarchive"}, args: []string{"src"}, dir: filesDir},
+	{names: []string{"assemble"}, args: []string{"src"}, dir: filesDir, remoteSrc: true},
+	{names: []string{"synchronize", "ansible.posix.synchronize"}, args: []string{"src"}, dir: filesDir},
+	{names: []string{"patch", "ansible.posix.patch"}, args: []string{"src"}, dir: filesDir},
+}
+
+// lookupFileModule returns entry of fileModules matching module name
+func lookupFileModule(name string) (fileModule, bool) {
+	for _, prefix := range []string{"ansible.builtin.", "ansible.legacy."} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	for _, m := range fileModules {
+		for _, v := range m.names {
+			if v == name {
+				return m, true
+			}
+		}
+	}
+	return fileModule{}, false
+}
+
+// isTrue reports whether module argument is truthy boolean
+func isTrue(v string) bool {
+	switch strings.ToLower(v) {
+	case "yes", "true", "on", "1", "y":
+		return true
+	}
+	return false
+}
+
+// needlePaths returns candidates of relative path name looked up from dir
+// of role then playbook, same order as ansible does
+func needlePaths(dir string, name string, sc scope) []string {
+	if path.IsAbs(name) {
+		return []string{name}
+	}
+	paths := []string{}
+	if sc.rolePath != "" {
+		paths = append(paths, path.Join(sc.rolePath, dir, name), path.Join(sc.rolePath, name))
+	}
+	return append(paths, path.Join(sc.playbookRoot, dir, name), path.Join(sc.playbookRoot, name))
+}
+
+// findNeedle returns first existing path among needlePaths, empty if none
+func (w *walker) findNeedle(dir string, name string, sc scope) (string, error) {
+	for _, p := range needlePaths(dir, name, sc) {
+		if exist, err := w.ds.IsExist(p); err != nil {
+			return "", errors.Wrapf(err, "ds.IsExist path=%s", p)
+		} else if exist {
+			return p, nil
+		}
+	}
+	return "", nil
+}
+
+// findNeedles returns paths which possibly templated name refers to, an
+// unresolved name is globbed within first search dir having any match
+func (w *walker) findNeedles(dir string, name string, sc scope, wantDir bool) ([]string, error) {
+	if rendered, ok := renderString(name, sc.vars); ok {
+		p, err := w.findNeedle(dir, rendered, sc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "findNeedle name=%s", rendered)
+		} else if p == "" {
+			return nil, nil
+		}
+		return []string{p}, nil
+	}
+	pattern := templateGlob(name, sc.vars)
+	log.Printf("Unresolved name '%s', fallback to glob '%s'", name, pattern)
+	for _, base := range needlePaths(dir, "", sc) {
+		if path.IsAbs(pattern) {
+			base = "/"
+		}
+		matches, err := w.globPath(base, pattern, wantDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "globPath dir=%s pattern=%s", base, pattern)
+		}
+		if len(matches) > 0 {
+			return matches, nil
+		}
+	}
+	return nil, nil
+}
+
+// parseSources returns local files read by module of task
+func (w *walker) parseSources(task Task, sc scope) ([]string, error) {
+	m, ok := lookupFileModule(task.Module)
+	if !ok {
+		return nil, nil
+	}
+	remoteSrc := m.remoteSrc
+	if v, ok := task.Args["remote_src"]; ok {
+		remoteSrc = isTrue(v)
+	} else if v, ok := task.Args["copy"]; ok {
+		// legacy option of unarchive
+		remoteSrc = !isTrue(v)
+	}
+	if remoteSrc {
+		return nil, nil
+	}
+	var src string
+	for _, arg := range m.args {
+		if src = task.Args[arg]; src != "" {
+			if arg == "cmd" || arg == "_raw_params" {
+				// script is followed by its own arguments
+				fields := strings.Fields(src)
+				if len(fields) == 0 {
+					src = ""
+					continue
+				}
+				src = fields[0]
+			}
+			break
+		}
+	}
+	if src == "" {
+		return nil, nil
+	}
+	paths, err := w.findNeedles(m.dir, src, sc, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "findNeedles src=%s", src)
+	}
+	// missing ones are generated at runtime or left to ansible
+	var deps []string
+	for _, p := range paths {
+		deps = append(deps, p)
+		if m.dir != templatesDir {
+			continue
+		}
+		tDeps, tErr := w.parseTemplate(p, sc, map[string]bool{})
+		if tErr != nil {
+			return nil, errors.Wrapf(tErr, "parseTemplate src=%s", p)
+		}
+		deps = append(deps, tDeps...)
+	}
+	return deps, nil
+}
@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestParseSources(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	role := scope{playbookRoot: "pb", rolePath: "pb/roles/web"}
+	play := scope{playbookRoot: "pb"}
+	for _, c := range []struct {
+		caseName string
+		task     string
+		sc       scope
+		setup    func()
+		err      bool
+		want     []string
+	}{
+		{
+			caseName: "template_of_role",
+			task:     `template: src=nginx.conf.j2 dest=/etc/nginx/nginx.conf`,
+			sc:       role,
+			setup: func() {
+				ds.SetFile("pb/roles/web/templates/nginx.conf.j2", []byte(""))
+				ds.SetFile("pb/templates/nginx.conf.j2", []byte(""))
+			},
+			want: []string{"pb/roles/web/templates/nginx.conf.j2"},
+		},
+		{
+			caseName: "template_fallback_to_playbook",
+			task: `
+ansible.builtin.template:
+  src: site.conf.j2
+  dest: /etc/site.conf`,
+			sc: role,
+			setup: func() {
+				ds.SetFile("pb/templates/site.conf.j2", []byte(""))
+			},
+			want: []string{"pb/templates/site.conf.j2"},
+		},
+		{
+			caseName: "copy_with_files_prefix",
+			task:     `copy: src=files/cert.pem dest=/etc/ssl/cert.pem`,
+			sc:       play,
+			setup: func() {
+				ds.SetFile("pb/files/cert.pem", []byte(""))
+			},
+			want: []string{"pb/files/cert.pem"},
+		},
+		{
+			caseName: "copy_of_remote_source",
+			task: `
+copy:
+  src: /tmp/cert.pem
+  dest: /etc/ssl/cert.pem
+  remote_src: yes`,
+			sc:    role,
+			setup: func() {},
+			want:  nil,
+		},
+		{
+			caseName: "script_with_arguments",
+			task:     `script: bootstrap.sh --force`,
+			sc:       role,
+			setup: func() {
+				ds.SetFile("pb/roles/web/files/bootstrap.sh", []byte(""))
+			},
+			want: []string{"pb/roles/web/files/bootstrap.sh"},
+		},
+		{
+			caseName: "script_with_cmd_arg",
+			task: `
+script:
+  cmd: bootstrap.sh --force
+  creates: /etc/bootstrapped`,
+			sc: role,
+			setup: func() {
+				ds.SetFile("pb/roles/web/files/bootstrap.sh", []byte(""))
+			},
+			want: []string{"pb/roles/web/files/bootstrap.sh"},
+		},
+		{
+			caseName: "script_with_blank_cmd",
+			task:     `script: {cmd: " "}`,
+			sc:       role,
+			setup:    func() {},
+			want:     nil,
+		},
+		{
+			caseName: "unarchive_with_legacy_copy_option",
+			task:     `unarchive: src=/opt/app.tgz dest=/srv copy=no`,
+			sc:       role,
+			setup:    func() {},
+			want:     nil,
+		},
+		{
+			caseName: "assemble_from_controller",
+			task: `
+action: assemble src=fragments dest=/etc/app.conf
+args:
+  remote_src: false`,
+			sc: role,
+			setup: func() {
+				ds.SetFile("pb/roles/web/files/fragments/01.conf", []byte(""))
+			},
+			want: []string{"pb/roles/web/files/fragments"},
+		},
+		{
+			caseName: "assemble_on_remote_by_default",
+			task:     `assemble: src=/etc/app.d dest=/etc/app.conf`,
+			sc:       role,
+			setup:    func() {},
+			want:     nil,
+		},
+		{
+			caseName: "synchronize_of_collection",
+			task:     `ansible.posix.synchronize: src=site/ dest=/srv/www`,
+			sc:       role,
+			setup: func() {
+				ds.SetFile("pb/roles/web/files/site/index.html", []byte(""))
+			},
+			want: []string{"pb/roles/web/files/site"},
+		},
+		{
+			caseName: "templated_source_resolved",
+			task:     `template: src="{{ app }}.conf.j2" dest=/etc/app.conf`,
+			sc:       scope{playbookRoot: "pb", rolePath: "pb/roles/web", vars: map[string]interface{}{"app": "api"}},
+			setup: func() {
+				ds.SetFile("pb/roles/web/templates/api.conf.j2", []byte(""))
+				ds.SetFile("pb/roles/web/templates/web.conf.j2", []byte(""))
+			},
+			want: []string{"pb/roles/web/templates/api.conf.j2"},
+		},
+		{
+			caseName: "templated_source_glob",
+			task:     `template: src="{{ app }}.conf.j2" dest=/etc/app.conf`,
+			sc:       role,
+			setup: func() {
+				ds.SetFile("pb/roles/web/templates/api.conf.j2", []byte(""))
+				ds.SetFile("pb/roles/web/templates/web.conf.j2", []byte(""))
+				ds.SetFile("pb/roles/web/templates/web.ini.j2", []byte(""))
+			},
+			want: []string{"pb/roles/web/templates/api.conf.j2", "pb/roles/web/templates/web.conf.j2"},
+		},
+		{
+			caseName: "source_not_exist",
+			task:     `copy: src=missing.txt dest=/tmp/missing.txt`,
+			sc:       role,
+			setup:    func() {},
+			want:     nil,
+		},
+		{
+			caseName: "module_without_source",
+			task:     `file: path=/tmp state=directory`,
+			sc:       role,
+			setup:    func() {},
+			want:     nil,
+		},
+		{
+			caseName: "unexpected_error",
+			task:     `copy: src=cert.pem dest=/etc/ssl/cert.pem`,
+			sc:       role,
+			setup: func() {
+				ds.SetFile("pb/roles/web/files/cert.pem", []byte("unexpected_error"))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			task := Task{}
+			require.NoError(t, yaml.Unmarshal([]byte(c.task), &task))
+			w := &walker{cfg: &Config{}, ds: ds}
+			out, err := w.parseSources(task, c.sc)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
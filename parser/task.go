@@ -0,0 +1,413 @@
+package parser
+
+import (
+	"log"
+	"path"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Task with file includes
+type Task struct {
+	Name         string                 `yaml:"name"`
+	IncludeTasks TasksInclude           `yaml:"include_tasks"`
+	ImportTasks  TasksInclude           `yaml:"import_tasks"`
+	Include      TasksInclude           `yaml:"include"`
+	IncludeRole  RoleInclude            `yaml:"include_role"`
+	ImportRole   RoleInclude            `yaml:"import_role"`
+	IncludeVars  VarsInclude            `yaml:"include_vars"`
+	Vars         map[string]interface{} `yaml:"vars"`
+	Notify       StringList             `yaml:"notify"`
+	Listen       StringList             `yaml:"listen"`
+	Block        []Task                 `yaml:"block"`
+	Rescue       []Task                 `yaml:"rescue"`
+	Always       []Task                 `yaml:"always"`
+	// Module is name of module called by task
+	Module string `yaml:"-"`
+	// Args holds scalar arguments of module
+	Args map[string]string `yaml:"-"`
+	// Filters lists jinja filters used within task
+	Filters []string `yaml:"-"`
+	// Lookups lists file reading lookups called within task
+	Lookups []Lookup `yaml:"-"`
+	// Loop is nil for task without loop
+	Loop *Loop `yaml:"-"`
+}
+
+// TasksInclude is file argument of include_tasks, import_tasks & legacy
+// include tasks
+type TasksInclude string
+
+// UnmarshalYAML accepts plain file name, free-form `file=foo` or mapping with
+// `file` key along with options such as `apply`
+func (ti *TasksInclude) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw string
+	if err := unmarshal(&raw); err == nil {
+		args := parseArgs(raw)
+		file := args["file"]
+		if file == "" {
+			file = args["_raw_params"]
+		}
+		*ti = TasksInclude(file)
+		return nil
+	}
+	var mapping struct {
+		File string `yaml:"file"`
+	}
+	if err := unmarshal(&mapping); err != nil {
+		return err
+	}
+	*ti = TasksInclude(mapping.File)
+	return nil
+}
+
+// UnmarshalYAML decodes task keywords along with module & filters it uses
+func (t *Task) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Task
+	fields := map[string]interface{}{}
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+	if err := unmarshalNormalized(unmarshal, fields, (*plain)(t)); err != nil {
+		return err
+	}
+	t.Module = moduleOf(fields)
+	t.Args = argsOf(fields, t.Module)
+	t.Filters = filtersOf(fields)
+	t.Lookups = lookupsOf(fields)
+	t.Loop = loopOf(fields)
+	return nil
+}
+
+// scope tells where paths referenced by tasks are resolved
+type scope struct {
+	playbookRoot string
+	// rolePath is empty for play level tasks
+	rolePath string
+	// collections are searched for roles declared with short name
+	collections []string
+	// vars are statically known variables used to render templated names
+	vars map[string]interface{}
+	// taskDir is dir of task file being parsed, empty for play & role level
+	taskDir string
+}
+
+// taskRoot returns dir which relative task includes are resolved from
+func (sc scope) taskRoot() string {
+	if sc.rolePath != "" {
+		return path.Join(sc.rolePath, "tasks")
+	}
+	return sc.playbookRoot
+}
+
+// isTaskFile reports whether name has extension of yaml task file
+func isTaskFile(name string) bool {
+	switch path.Ext(name) {
+	case ".yml", ".yaml":
+		return true
+	}
+	return false
+}
+
+// taskPath returns path of included task file
+func (sc scope) taskPath(name string) string {
+	if path.IsAbs(name) {
+		return name
+	}
+	return path.Join(sc.taskRoot(), name)
+}
+
+// taskSearchDirs returns dirs which relative task includes are searched in
+// as ansible DWIM lookup does: dir of including file, tasks dir & dir of
+// role then playbook dir
+func (sc scope) taskSearchDirs() []string {
+	dirs := []string{}
+	if sc.taskDir != "" {
+		dirs = append(dirs, sc.taskDir)
+	}
+	if sc.rolePath != "" {
+		dirs = append(dirs, path.Join(sc.rolePath, "tasks"), sc.rolePath)
+	}
+	return uniq(append(dirs, sc.playbookRoot))
+}
+
+// findTask returns path of first task file found for name within search
+// dirs, name without extension may refer to yaml file, empty if not found
+func (w *walker) findTask(name string, sc scope) (string, error) {
+	dirs := sc.taskSearchDirs()
+	if path.IsAbs(name) {
+		dirs = []string{"/"}
+	}
+	exts := []string{""}
+	if path.Ext(name) == "" {
+		exts = append(exts, ".yml", ".yaml")
+	}
+	for _, dir := range dirs {
+		for _, ext := range exts {
+			p := path.Join(dir, name+ext)
+			if exist, err := w.ds.IsExist(p); err != nil {
+				return "", errors.Wrapf(err, "ds.IsExist path=%s", p)
+			} else if !exist {
+				continue
+			}
+			if isDir, err := w.ds.IsDir(p); err != nil {
+				return "", errors.Wrapf(err, "ds.IsDir path=%s", p)
+			} else if !isDir {
+				return p, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// findTasks returns task files which possibly templated include name refers
+// to, an unresolved name is globbed within first search dir having any
+// match other than files being parsed, missing file is an error unless
+// optional
+func (w *walker) findTasks(name string, sc scope, optional bool) ([]string, error) {
+	if rendered, ok := renderString(name, sc.vars); ok {
+		p, err := w.findTask(rendered, sc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "findTask name=%s", rendered)
+		}
+		if p != "" {
+			return []string{p}, nil
+		}
+		if optional {
+			return nil, nil
+		}
+		return nil, errors.Errorf("task file %s was not found in %+v", rendered, sc.taskSearchDirs())
+	}
+	pattern := templateGlob(name, sc.vars)
+	log.Printf("Unresolved name '%s', fallback to glob '%s'", name, pattern)
+	dirs := sc.taskSearchDirs()
+	if path.IsAbs(pattern) {
+		dirs = []string{"/"}
+	}
+	for _, dir := range dirs {
+		matches, err := w.globPath(dir, pattern, false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "globPath dir=%s pattern=%s", dir, pattern)
+		}
+		// globbed names may hit other files of dir or the including file
+		files := []string{}
+		for _, m := range matches {
+			if !isTaskFile(m) || w.entered(m) {
+				continue
+			}
+			ok, tErr := w.isTaskList(m)
+			if tErr != nil {
+				return nil, errors.Wrapf(tErr, "isTaskList path=%s", m)
+			}
+			if ok {
+				files = append(files, m)
+			}
+		}
+		if len(files) > 0 {
+			return files, nil
+		}
+	}
+	return nil, nil
+}
+
+// isTaskList reports whether yaml file holds list of tasks rather than
+// playbook or other yaml data, vaulted file is taken as tasks
+func (w *walker) isTaskList(file string) (bool, error) {
+	content, err := w.ds.ReadFile(file)
+	if err != nil {
+		return false, errors.Wrapf(err, "dataSource file_path=%s", file)
+	}
+	if isVaulted(string(content)) {
+		return true, nil
+	}
+	items := []map[string]interface{}{}
+	if err = yaml.Unmarshal(content, &items); err != nil {
+		log.Printf("Skip globbed file '%s' not holding tasks", file)
+		return false, nil
+	}
+	for _, item := range items {
+		normalizeKeywords(item)
+		for _, key := range []string{"hosts", "import_playbook"} {
+			if _, ok := item[key]; ok {
+				log.Printf("Skip globbed playbook '%s'", file)
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// parseTask returns task file and its includes, roles included by tasks are
+// searched from playbook root
+func (w *walker) parseTask(name string, sc scope) ([]string, error) {
+	// log.Printf("Parse task '%s' scope=%+v", name, sc)
+	filePath, err := w.findTask(name, sc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "findTask name=%s", name)
+	} else if filePath == "" {
+		// missing file is reported on reading it
+		filePath = sc.taskPath(name)
+	}
+	return w.parseTaskFile(filePath, sc)
+}
+
+// parseTaskFile returns task file found at filePath and its includes
+func (w *walker) parseTaskFile(filePath string, sc scope) ([]string, error) {
+	deps := []string{filePath}
+	if ok, err := w.enter(filePath); !ok {
+		return deps, err
+	}
+	defer w.leave()
+
+	content, err := w.ds.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dataSource file_path=%s", filePath)
+	}
+	if w.checkVault(filePath, content) {
+		// encrypted tasks are opaque
+		return deps, nil
+	}
+	taskList := []Task{}
+	if err = yaml.Unmarshal(content, &taskList); err != nil {
+		return nil, errors.Wrapf(err, "yaml.Unmarshal file_path=%s", filePath)
+	}
+	// includes of file are searched from its own dir first
+	sc.taskDir = path.Dir(filePath)
+	tDeps, err := w.parseTaskList(taskList, sc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parseTaskList file_path=%s", filePath)
+	}
+	return append(deps, tDeps...), nil
+}
+
+// parseTaskList resolves includes of tasks within given scope
+func (w *walker) parseTaskList(taskList []Task, sc scope) ([]string, error) {
+	var err error
+	deps := []string{}
+	for _, task := range taskList {
+		// task vars are visible to the task & files it includes
+		tsc := sc
+		tsc.vars = mergeVars(sc.vars, task.Vars)
+		// includes & sources are parsed once per loop item
+		lscs, optional := loopScopes(task.Loop, tsc)
+		parseInclude := func(name string) error {
+			for _, lsc := range lscs {
+				candidates, ffOptional := includeNames(name, lsc)
+				for _, candidate := range candidates {
+					// first_found candidates not existing are never picked
+					files, fErr := w.findTasks(candidate, lsc, optional || ffOptional)
+					if fErr != nil {
+						return errors.Wrapf(fErr, "findTasks name=%s", candidate)
+					}
+					for _, f := range files {
+						iDeps, iErr := w.parseTaskFile(f, lsc)
+						if iErr != nil {
+							return errors.Wrapf(iErr, "parseTaskFile path=%s", f)
+						}
+						deps = append(deps, iDeps...)
+					}
+				}
+			}
+			return nil
+		}
+		parseRoleInclude := func(ri RoleInclude) error {
+			for _, lsc := range lscs {
+				rsc := scope{playbookRoot: sc.playbookRoot, collections: sc.collections, vars: lsc.vars}
+				rDeps, rErr := w.parseRole(ri.Name, ri.EntryPoints, rsc)
+				if rErr != nil {
+					return errors.Wrapf(rErr, "parseRole name=%s", ri.Name)
+				}
+				deps = append(deps, rDeps...)
+			}
+			return nil
+		}
+
+		// nested task groups share the same scope
+		for _, group := range [][]Task{task.Block, task.Rescue, task.Always} {
+			gDeps, gErr := w.parseTaskList(group, tsc)
+			if gErr != nil {
+				return nil, errors.Wrapf(gErr, "parseTaskList block=%s", task.Name)
+			}
+			deps = append(deps, gDeps...)
+		}
+		if task.IncludeTasks != "" {
+			if err = parseInclude(string(task.IncludeTasks)); err != nil {
+				return nil, errors.Wrapf(err, "parseInclude include_tasks=%s", task.IncludeTasks)
+			}
+		}
+		if task.ImportTasks != "" {
+			if err = parseInclude(string(task.ImportTasks)); err != nil {
+				return nil, errors.Wrapf(err, "parseInclude import_tasks=%s", task.ImportTasks)
+			}
+		}
+		if task.Include != "" {
+			if err = parseInclude(string(task.Include)); err != nil {
+				return nil, errors.Wrapf(err, "parseInclude include=%s", task.Include)
+			}
+		}
+		if task.IncludeRole.Name != "" {
+			if err = parseRoleInclude(task.IncludeRole); err != nil {
+				return nil, errors.Wrapf(err, "parseRoleInclude include_role=%s", task.IncludeRole.Name)
+			}
+		}
+		if task.ImportRole.Name != "" {
+			if err = parseRoleInclude(task.ImportRole); err != nil {
+				return nil, errors.Wrapf(err, "parseRoleInclude import_role=%s", task.ImportRole.Name)
+			}
+		}
+		pDeps, pErr := w.parsePlugins(task, tsc)
+		if pErr != nil {
+			return nil, errors.Wrapf(pErr, "parsePlugins task=%s", task.Name)
+		}
+		deps = append(deps, pDeps...)
+		w.notify(task.Notify, tsc)
+		lDeps, lErr := w.parseLookups(task.Lookups, tsc, map[string]bool{})
+		if lErr != nil {
+			return nil, errors.Wrapf(lErr, "parseLookups task=%s", task.Name)
+		}
+		deps = append(deps, lDeps...)
+		for _, lsc := range lscs {
+			sDeps, sErr := w.parseSources(task, lsc)
+			if sErr != nil {
+				return nil, errors.Wrapf(sErr, "parseSources task=%s", task.Name)
+			}
+			deps = append(deps, sDeps...)
+			if task.IncludeVars.isEmpty() {
+				continue
+			}
+			vis := []VarsInclude{task.IncludeVars}
+			ffOptional := false
+			if task.IncludeVars.File != "" {
+				var names []string
+				names, ffOptional = includeNames(task.IncludeVars.File, lsc)
+				vis = []VarsInclude{}
+				for _, name := range names {
+					vi := task.IncludeVars
+					vi.File = name
+					vis = append(vis, vi)
+				}
+			}
+			for _, vi := range vis {
+				var vDeps []string
+				var vErr error
+				if vi.File != "" && (optional || ffOptional) {
+					// first_found candidates not existing are never picked
+					vDeps, vErr = w.findNeedles("vars", vi.File, lsc, false)
+				} else {
+					vDeps, vErr = w.parseVarsInclude(vi, lsc)
+				}
+				if vErr != nil {
+					return nil, errors.Wrapf(vErr, "parseVarsInclude include_vars=%+v", vi)
+				}
+				fDeps, fErr := w.parseFileLookups(vDeps, lsc)
+				if fErr != nil {
+					return nil, errors.Wrapf(fErr, "parseFileLookups include_vars=%+v", vi)
+				}
+				deps = append(deps, vDeps...)
+				deps = append(deps, fDeps...)
+			}
+		}
+	}
+	return deps, nil
+}
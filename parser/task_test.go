@@ -0,0 +1,462 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestParseTask(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		task     string
+		sc       scope
+		setup    func()
+		err      bool
+		want     []string
+	}{
+		{
+			caseName: "file_with_no_includes",
+			task:     "no-includes.yml",
+			setup: func() {
+				ds.SetFile("no-includes.yml", []byte(`
+- name: Assert true is not false
+  assert:
+    that: 1 != 0`))
+			},
+			want: []string{"no-includes.yml"},
+		},
+		{
+			caseName: "file_with_include_tasks_in_same_dir",
+			task:     "with-include_tasks.yml",
+			setup: func() {
+				ds.SetFile("with-include_tasks.yml", []byte(`
+- name: Do something
+  include_tasks: something.yml`))
+				ds.SetFile("something.yml", []byte(``))
+			},
+			want: []string{"with-include_tasks.yml", "something.yml"},
+		},
+		{
+			caseName: "file_with_include_tasks_in_relative_dir",
+			task:     "with-include_tasks-relative-dir.yml",
+			sc:       scope{rolePath: "/tmp/r1"},
+			setup: func() {
+				ds.SetFile("/tmp/r1/tasks/with-include_tasks-relative-dir.yml", []byte(`
+- name: Do more things
+  include_tasks: ../../morethings.yml`))
+				ds.SetFile("/tmp/morethings.yml", []byte(``))
+			},
+			want: []string{"/tmp/r1/tasks/with-include_tasks-relative-dir.yml", "/tmp/morethings.yml"},
+		},
+		{
+			caseName: "file_with_import_tasks",
+			task:     "with-import_tasks.yml",
+			sc:       scope{rolePath: "/tmp/r2"},
+			setup: func() {
+				ds.SetFile("/tmp/r2/tasks/with-import_tasks.yml", []byte(`
+- name: Do import
+  import_tasks: ../../staticthing.yml`))
+				ds.SetFile("/tmp/staticthing.yml", []byte(``))
+			},
+			want: []string{"/tmp/r2/tasks/with-import_tasks.yml", "/tmp/staticthing.yml"},
+		},
+		{
+			caseName: "file_with_include_tasks_mapping_and_free_form",
+			task:     "with-include_tasks-args.yml",
+			sc:       scope{rolePath: "/tmp/r16"},
+			setup: func() {
+				ds.SetFile("/tmp/r16/tasks/with-include_tasks-args.yml", []byte(`
+- include_tasks:
+    file: install.yml
+    apply:
+      tags: [install]
+- import_tasks: file=configure.yml`))
+				ds.SetFile("/tmp/r16/tasks/install.yml", []byte(``))
+				ds.SetFile("/tmp/r16/tasks/configure.yml", []byte(``))
+			},
+			want: []string{
+				"/tmp/r16/tasks/with-include_tasks-args.yml",
+				"/tmp/r16/tasks/install.yml",
+				"/tmp/r16/tasks/configure.yml",
+			},
+		},
+		{
+			caseName: "file_with_depricated_include",
+			task:     "with-depricated_include.yml",
+			sc:       scope{rolePath: "/tmp/r3"},
+			setup: func() {
+				ds.SetFile("/tmp/r3/tasks/with-depricated_include.yml", []byte(`
+- name: Do depricated include
+  include: ../../depricatedthing.yml`))
+				ds.SetFile("/tmp/depricatedthing.yml", []byte(``))
+			},
+			want: []string{"/tmp/r3/tasks/with-depricated_include.yml", "/tmp/depricatedthing.yml"},
+		},
+		{
+			caseName: "file_with_include_role_free_form",
+			task:     "with-include_role.yml",
+			setup: func() {
+				ds.SetFile("with-include_role.yml", []byte(`
+- name: Include monitoring
+  include_role: name=monitoring`))
+				ds.SetFile("roles/monitoring/tasks/main.yml", []byte(""))
+			},
+			want: []string{"with-include_role.yml", "roles/monitoring/tasks/main.yml"},
+		},
+		{
+			caseName: "file_with_import_role_dict",
+			task:     "with-import_role.yml",
+			setup: func() {
+				ds.SetFile("with-import_role.yml", []byte(`
+- name: Import db replica
+  import_role:
+    name: db
+    tasks_from: replica`))
+				ds.SetFile("roles/db/tasks/main.yml", []byte(""))
+				ds.SetFile("roles/db/tasks/replica.yml", []byte(""))
+			},
+			want: []string{"with-import_role.yml", "roles/db/tasks/replica.yml"},
+		},
+		{
+			caseName: "include_role_not_exist",
+			task:     "include_role_not_exist.yml",
+			setup: func() {
+				ds.SetFile("include_role_not_exist.yml", []byte(`
+- include_role:
+    name: role-not-exist`))
+			},
+			err: true,
+		},
+		{
+			caseName: "file_with_nested_blocks",
+			task:     "with-blocks.yml",
+			sc:       scope{rolePath: "/tmp/r9"},
+			setup: func() {
+				ds.SetFile("/tmp/r9/tasks/with-blocks.yml", []byte(`
+- name: Install
+  block:
+  - include_tasks: ../../install.yml
+  - block:
+    - import_tasks: ../../nested.yml
+  rescue:
+  - include_tasks: ../../rescue.yml
+  always:
+  - include_role:
+      name: cleanup`))
+				ds.SetFile("/tmp/install.yml", []byte(``))
+				ds.SetFile("/tmp/nested.yml", []byte(``))
+				ds.SetFile("/tmp/rescue.yml", []byte(``))
+				ds.SetFile("roles/cleanup/tasks/main.yml", []byte(``))
+			},
+			want: []string{"/tmp/r9/tasks/with-blocks.yml", "/tmp/install.yml", "/tmp/nested.yml", "/tmp/rescue.yml", "roles/cleanup/tasks/main.yml"},
+		},
+		{
+			caseName: "block_include_content_malformed",
+			task:     "block_malformed.yml",
+			sc:       scope{rolePath: "/tmp/r10"},
+			setup: func() {
+				ds.SetFile("/tmp/r10/tasks/block_malformed.yml", []byte(`
+- block:
+  - include_tasks: ../../malformed.yml`))
+				ds.SetFile("/tmp/malformed.yml", []byte(`abcde`))
+			},
+			err: true,
+		},
+		{
+			caseName: "file_with_include_vars",
+			task:     "with-include_vars.yml",
+			sc:       scope{rolePath: "/tmp/r11"},
+			setup: func() {
+				ds.SetFile("/tmp/r11/tasks/with-include_vars.yml", []byte(`
+- include_vars: prod.yml
+- include_vars: file=common.yml
+- include_vars:
+    dir: conf.d`))
+				ds.SetFile("/tmp/r11/vars/prod.yml", []byte(``))
+				ds.SetFile("/tmp/r11/vars/common.yml", []byte(``))
+				ds.SetFile("/tmp/r11/vars/conf.d/app.yml", []byte(``))
+			},
+			want: []string{
+				"/tmp/r11/tasks/with-include_vars.yml",
+				"/tmp/r11/vars/prod.yml",
+				"/tmp/r11/vars/common.yml",
+				"/tmp/r11/vars/conf.d/app.yml",
+			},
+		},
+		{
+			caseName: "file_with_loop_includes",
+			task:     "with-loop.yml",
+			sc:       scope{rolePath: "/tmp/r12"},
+			setup: func() {
+				ds.SetFile("/tmp/r12/tasks/with-loop.yml", []byte(`
+- include_tasks: "{{ item }}.yml"
+  loop: [users, packages]
+- include_tasks: "setup_{{ pkg }}.yml"
+  with_items:
+  - [git, curl]
+  loop_control:
+    loop_var: pkg
+- include_tasks: "{{ item }}"
+  loop: "{{ extra_tasks }}"
+  vars:
+    extra_tasks: [cleanup.yml]`))
+				ds.SetFile("/tmp/r12/tasks/users.yml", []byte(``))
+				ds.SetFile("/tmp/r12/tasks/packages.yml", []byte(``))
+				ds.SetFile("/tmp/r12/tasks/setup_git.yml", []byte(``))
+				ds.SetFile("/tmp/r12/tasks/setup_curl.yml", []byte(``))
+				ds.SetFile("/tmp/r12/tasks/setup_vim.yml", []byte(``))
+				ds.SetFile("/tmp/r12/tasks/cleanup.yml", []byte(``))
+			},
+			want: []string{
+				"/tmp/r12/tasks/with-loop.yml",
+				"/tmp/r12/tasks/users.yml",
+				"/tmp/r12/tasks/packages.yml",
+				"/tmp/r12/tasks/setup_git.yml",
+				"/tmp/r12/tasks/setup_curl.yml",
+				"/tmp/r12/tasks/cleanup.yml",
+			},
+		},
+		{
+			caseName: "file_with_loop_of_unknown_items",
+			task:     "main.yml",
+			sc:       scope{rolePath: "/tmp/r15"},
+			setup: func() {
+				ds.SetFile("/tmp/r15/tasks/main.yml", []byte(`
+- include_tasks: "{{ item }}.yml"
+  loop: "{{ pkgs }}"`))
+				ds.SetFile("/tmp/r15/tasks/nginx.yml", []byte(``))
+				ds.SetFile("/tmp/r15/tasks/redis.yml", []byte(``))
+			},
+			want: []string{
+				"/tmp/r15/tasks/main.yml",
+				"/tmp/r15/tasks/nginx.yml",
+				"/tmp/r15/tasks/redis.yml",
+			},
+		},
+		{
+			caseName: "file_with_first_found_includes",
+			task:     "with-first-found.yml",
+			sc:       scope{rolePath: "/tmp/r13"},
+			setup: func() {
+				ds.SetFile("/tmp/r13/tasks/with-first-found.yml", []byte(`
+- include_vars: "{{ item }}"
+  with_first_found:
+  - "os_{{ ansible_distribution }}.yml"
+  - default.yml
+- include_tasks: "{{ lookup('first_found', params) }}"
+  vars:
+    params:
+      files: setup-redhat.yml,setup-debian.yml
+      paths: [os]
+- include_vars: "{{ query('first_found', ['missing.yml', 'main.yml']) }}"`))
+				ds.SetFile("/tmp/r13/vars/os_Debian.yml", []byte(``))
+				ds.SetFile("/tmp/r13/vars/os_RedHat.yml", []byte(``))
+				ds.SetFile("/tmp/r13/vars/default.yml", []byte(``))
+				ds.SetFile("/tmp/r13/vars/main.yml", []byte(``))
+				ds.SetFile("/tmp/r13/tasks/os/setup-debian.yml", []byte(``))
+			},
+			want: []string{
+				"/tmp/r13/tasks/with-first-found.yml",
+				"/tmp/r13/vars/os_Debian.yml",
+				"/tmp/r13/vars/os_RedHat.yml",
+				"/tmp/r13/vars/default.yml",
+				"/tmp/r13/tasks/os/setup-debian.yml",
+				"/tmp/r13/vars/main.yml",
+			},
+		},
+		{
+			caseName: "loop_include_not_exist",
+			task:     "loop_include_not_exist.yml",
+			setup: func() {
+				ds.SetFile("loop_include_not_exist.yml", []byte(`
+- include_tasks: "{{ item }}.yml"
+  loop: [not-exist]`))
+			},
+			err: true,
+		},
+		{
+			caseName: "file_with_includes_of_role_search_path",
+			task:     "main.yml",
+			sc:       scope{playbookRoot: "/tmp/pb", rolePath: "/tmp/pb/roles/r14"},
+			setup: func() {
+				ds.SetFile("/tmp/pb/roles/r14/tasks/main.yml", []byte(`
+- import_tasks: tasks/install/main.yml
+- include_tasks: setup
+- include_tasks: shared/notify.yml`))
+				ds.SetFile("/tmp/pb/roles/r14/tasks/install/main.yml", []byte(`
+- include_tasks: apt.yml
+- include_tasks: common.yml
+- include_tasks: ../../common/tasks/users.yml`))
+				ds.SetFile("/tmp/pb/roles/r14/tasks/install/apt.yml", []byte(``))
+				ds.SetFile("/tmp/pb/roles/r14/tasks/common.yml", []byte(``))
+				ds.SetFile("/tmp/pb/roles/r14/tasks/setup.yaml", []byte(``))
+				ds.SetFile("/tmp/pb/roles/common/tasks/users.yml", []byte(``))
+				ds.SetFile("/tmp/pb/shared/notify.yml", []byte(``))
+			},
+			want: []string{
+				"/tmp/pb/roles/r14/tasks/main.yml",
+				"/tmp/pb/roles/r14/tasks/install/main.yml",
+				"/tmp/pb/roles/r14/tasks/install/apt.yml",
+				"/tmp/pb/roles/r14/tasks/common.yml",
+				"/tmp/pb/roles/common/tasks/users.yml",
+				"/tmp/pb/roles/r14/tasks/setup.yaml",
+				"/tmp/pb/shared/notify.yml",
+			},
+		},
+		{
+			caseName: "file_with_includes_of_playbook_search_path",
+			task:     "tasks/deploy.yml",
+			sc:       scope{playbookRoot: "/tmp/pb"},
+			setup: func() {
+				ds.SetFile("/tmp/pb/tasks/deploy.yml", []byte(`
+- include_tasks: steps/fetch.yml
+- include_tasks: shared/notify.yml`))
+				ds.SetFile("/tmp/pb/tasks/steps/fetch.yml", []byte(`
+- include_tasks: verify`))
+				ds.SetFile("/tmp/pb/tasks/steps/verify", []byte(``))
+				ds.SetFile("/tmp/pb/shared/notify.yml", []byte(``))
+			},
+			want: []string{
+				"/tmp/pb/tasks/deploy.yml",
+				"/tmp/pb/tasks/steps/fetch.yml",
+				"/tmp/pb/tasks/steps/verify",
+				"/tmp/pb/shared/notify.yml",
+			},
+		},
+		{
+			caseName: "include_vars_not_exist",
+			task:     "include_vars_not_exist.yml",
+			setup: func() {
+				ds.SetFile("include_vars_not_exist.yml", []byte(`
+- include_vars: not-exist.yml`))
+			},
+			err: true,
+		},
+		{
+			caseName: "task_not_exist",
+			task:     "task_not_exist.yml",
+			setup:    func() {},
+			err:      true,
+		},
+		{
+			caseName: "task_file_content_malformed",
+			task:     "malformed.yml",
+			sc:       scope{rolePath: "/tmp/r4"},
+			setup: func() {
+				ds.SetFile("/tmp/r4/tasks/malformed.yml", []byte(`abcde`))
+			},
+			err: true,
+		},
+		{
+			caseName: "include_tasks_content_malformed",
+			task:     "include_tasks_malformed.yml",
+			sc:       scope{rolePath: "/tmp/r5"},
+			setup: func() {
+				ds.SetFile("/tmp/r5/tasks/include_tasks_malformed.yml", []byte(`
+- name: Do include tasks with malformed content
+  include_tasks: ../../malformed.yml`))
+				ds.SetFile("/tmp/malformed.yml", []byte(`abcde`))
+			},
+			err: true,
+		},
+		{
+			caseName: "import_tasks_content_malformed",
+			task:     "import_tasks_malformed.yml",
+			sc:       scope{rolePath: "/tmp/r7"},
+			setup: func() {
+				ds.SetFile("/tmp/r7/tasks/import_tasks_malformed.yml", []byte(`
+- name: Do import tasks with malformed content
+  import_tasks: ../../malformed.yml`))
+				ds.SetFile("/tmp/malformed.yml", []byte(`abcde`))
+			},
+			err: true,
+		},
+		{
+			caseName: "depricated_include_content_malformed",
+			task:     "depricated_include_malformed.yml",
+			sc:       scope{rolePath: "/tmp/r8"},
+			setup: func() {
+				ds.SetFile("/tmp/r8/tasks/depricated_include_malformed.yml", []byte(`
+- name: Do depricated include with malformed content
+  include: ../../malformed.yml`))
+				ds.SetFile("/tmp/malformed.yml", []byte(`abcde`))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			sc := c.sc
+			if sc.rolePath == "" && sc.playbookRoot == "" {
+				sc.playbookRoot = "."
+			}
+			w := &walker{cfg: &Config{}, ds: ds}
+			out, err := w.parseTask(c.task, sc)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
+
+func TestTasksIncludeUnmarshalYAML(t *testing.T) {
+	for _, c := range []struct {
+		caseName string
+		content  string
+		err      bool
+		want     TasksInclude
+	}{
+		{
+			caseName: "plain_file",
+			content:  `setup.yml`,
+			want:     "setup.yml",
+		},
+		{
+			caseName: "templated_file",
+			content:  `"setup_{{ ansible_os_family }}.yml"`,
+			want:     "setup_{{ ansible_os_family }}.yml",
+		},
+		{
+			caseName: "free_form",
+			content:  `file=setup.yml`,
+			want:     "setup.yml",
+		},
+		{
+			caseName: "legacy_include_with_params",
+			content:  `setup.yml user=deploy`,
+			want:     "setup.yml",
+		},
+		{
+			caseName: "mapping",
+			content:  `{file: setup.yml, apply: {tags: [setup], become: true}}`,
+			want:     "setup.yml",
+		},
+		{
+			caseName: "malformed",
+			content:  `[setup.yml]`,
+			err:      true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			var out TasksInclude
+			err := yaml.Unmarshal([]byte(c.content), &out)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
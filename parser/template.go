@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// statements loading other templates, name expression is captured
+	templateRefPattern = regexp.MustCompile(`(?s)\{%[-+]?\s*(?:include|import|from|extends)\s+(.+?)\s*[-+]?%\}`)
+	// keywords ending name expression of statements
+	templateRefEndPattern = regexp.MustCompile(`\s+(?:as|import|ignore|with|without)\s`)
+	stringLiteralPattern  = regexp.MustCompile(`^(?:'([^']*)'|"([^"]*)")$`)
+)
+
+// templateRefs returns literal names of templates loaded by jinja source,
+// names built from variables are skipped
+func templateRefs(content []byte) []string {
+	refs := []string{}
+	for _, m := range templateRefPattern.FindAllStringSubmatch(string(content), -1) {
+		expr := m[1] + " "
+		if loc := templateRefEndPattern.FindStringIndex(expr); loc != nil {
+			expr = expr[:loc[0]]
+		}
+		expr = strings.Trim(strings.TrimSpace(expr), "[]()")
+		for _, item := range strings.Split(expr, ",") {
+			lit := stringLiteralPattern.FindStringSubmatch(strings.TrimSpace(item))
+			if lit == nil {
+				continue
+			}
+			if name := lit[1] + lit[2]; name != "" && !strings.Contains(name, "{{") {
+				refs = append(refs, name)
+			}
+		}
+	}
+	return refs
+}
+
+// parseTemplate returns templates loaded by template file recursively,
+// searched in `templates` dir of role & playbook then dir of template itself
+// as ansible template loader does
+func (w *walker) parseTemplate(file string, sc scope, seen map[string]bool) ([]string, error) {
+	if seen[file] {
+		return nil, nil
+	}
+	seen[file] = true
+	content, err := w.ds.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dataSource file_path=%s", file)
+	}
+	deps, err := w.parseLookups(lookupsIn(string(content)), sc, seen)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parseLookups file_path=%s", file)
+	}
+	for _, name := range templateRefs(content) {
+		p, fErr := w.findNeedle(templatesDir, name, sc)
+		if fErr != nil {
+			return nil, errors.Wrapf(fErr, "findNeedle name=%s", name)
+		}
+		if p == "" {
+			local := path.Join(path.Dir(file), name)
+			if exist, eErr := w.ds.IsExist(local); eErr != nil {
+				return nil, errors.Wrapf(eErr, "ds.IsExist path=%s", local)
+			} else if exist {
+				p = local
+			}
+		}
+		if p == "" || seen[p] {
+			// missing one may be optional with `ignore missing`
+			continue
+		}
+		sub, sErr := w.parseTemplate(p, sc, seen)
+		if sErr != nil {
+			return nil, errors.Wrapf(sErr, "parseTemplate file_path=%s", p)
+		}
+		deps = append(deps, p)
+		deps = append(deps, sub...)
+	}
+	return deps, nil
+}
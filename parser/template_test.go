@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestTemplateRefs(t *testing.T) {
+	content := []byte(`{% extends "base.j2" %}
+{% block body %}
+{%- include 'partials/ssl.j2' -%}
+{% include ['partials/' ~ env ~ '.j2', 'partials/default.j2'] ignore missing %}
+{% include "partials/" + name %}
+{% import 'macros.j2' as macros %}
+{% from "forms.j2" import input with context %}
+{% include template_name %}
+{% endblock %}`)
+	assert.Equal(t, []string{
+		"base.j2",
+		"partials/ssl.j2",
+		"partials/default.j2",
+		"macros.j2",
+		"forms.j2",
+	}, templateRefs(content))
+}
+
+func TestParseTemplate(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	sc := scope{playbookRoot: "pb", rolePath: "pb/roles/web"}
+	for _, c := range []struct {
+		caseName string
+		file     string
+		setup    func()
+		err      bool
+		want     []string
+	}{
+		{
+			caseName: "nested_includes",
+			file:     "pb/roles/web/templates/nginx.conf.j2",
+			setup: func() {
+				ds.SetFile("pb/roles/web/templates/nginx.conf.j2", []byte(`
+{% extends 'base.j2' %}
+{% include 'partials/ssl.j2' %}`))
+				ds.SetFile("pb/roles/web/templates/partials/ssl.j2", []byte(`
+{% import 'macros.j2' as m %}`))
+				ds.SetFile("pb/templates/base.j2", []byte(""))
+				ds.SetFile("pb/templates/macros.j2", []byte(`
+{% include 'partials/ssl.j2' %}`))
+			},
+			want: []string{
+				"pb/templates/base.j2",
+				"pb/roles/web/templates/partials/ssl.j2",
+				"pb/templates/macros.j2",
+			},
+		},
+		{
+			caseName: "template_of_other_role",
+			file:     "pb/roles/web/templates/site.j2",
+			setup: func() {
+				ds.SetFile("pb/roles/web/templates/site.j2", []byte(`
+{% include 'roles/common/templates/header.j2' %}`))
+				ds.SetFile("pb/roles/common/templates/header.j2", []byte(""))
+			},
+			want: []string{"pb/roles/common/templates/header.j2"},
+		},
+		{
+			caseName: "relative_to_template_dir",
+			file:     "pb/roles/web/templates/sites/app.j2",
+			setup: func() {
+				ds.SetFile("pb/roles/web/templates/sites/app.j2", []byte(`
+{% include 'location.j2' %}`))
+				ds.SetFile("pb/roles/web/templates/sites/location.j2", []byte(""))
+			},
+			want: []string{"pb/roles/web/templates/sites/location.j2"},
+		},
+		{
+			caseName: "missing_include",
+			file:     "pb/roles/web/templates/app.j2",
+			setup: func() {
+				ds.SetFile("pb/roles/web/templates/app.j2", []byte(`
+{% include 'optional.j2' ignore missing %}`))
+			},
+			want: []string{},
+		},
+		{
+			caseName: "template_not_exist",
+			file:     "pb/roles/web/templates/missing.j2",
+			setup:    func() {},
+			err:      true,
+		},
+		{
+			caseName: "unexpected_error",
+			file:     "pb/roles/web/templates/app.j2",
+			setup: func() {
+				ds.SetFile("pb/roles/web/templates/app.j2", []byte(`
+{% include 'broken.j2' %}`))
+				ds.SetFile("pb/roles/web/templates/broken.j2", []byte("unexpected_error"))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			w := &walker{cfg: &Config{}, ds: ds}
+			out, err := w.parseTemplate(c.file, sc, map[string]bool{})
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package parser
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// VarsInclude is argument of include_vars task, loading either single file
+// or all matching files within dir
+type VarsInclude struct {
+	File          string     `yaml:"file"`
+	Dir           string     `yaml:"dir"`
+	FilesMatching string     `yaml:"files_matching"`
+	IgnoreFiles   StringList `yaml:"ignore_files"`
+	Extensions    StringList `yaml:"extensions"`
+	Depth         int        `yaml:"depth"`
+}
+
+// UnmarshalYAML accepts plain file name, free-form `file=foo` or mapping syntax
+func (vi *VarsInclude) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw string
+	if err := unmarshal(&raw); err == nil {
+		args := parseArgs(raw)
+		vi.File = args["file"]
+		if vi.File == "" {
+			vi.File = args["_raw_params"]
+		}
+		vi.Dir = args["dir"]
+		vi.FilesMatching = args["files_matching"]
+		if v, ok := args["ignore_files"]; ok {
+			vi.IgnoreFiles = strings.Split(v, ",")
+		}
+		if v, ok := args["extensions"]; ok {
+			vi.Extensions = strings.Split(v, ",")
+		}
+		if v, ok := args["depth"]; ok {
+			if vi.Depth, err = strconv.Atoi(v); err != nil {
+				return errors.Wrapf(err, "strconv.Atoi depth=%s", v)
+			}
+		}
+		return nil
+	}
+	type plain VarsInclude
+	return unmarshal((*plain)(vi))
+}
+
+func (vi VarsInclude) isEmpty() bool {
+	return vi.File == "" && vi.Dir == ""
+}
+
+// findVarsPath returns first existing path of vars source, looking in role
+// dir then playbook dir as ansible does
+func (w *walker) findVarsPath(name string, sc scope) (string, error) {
+	p, err := w.findNeedle("vars", name, sc)
+	if err != nil {
+		return "", errors.Wrapf(err, "findNeedle name=%s", name)
+	} else if p == "" {
+		return "", errors.Errorf("vars %s was not found in %+v", name, needlePaths("vars", name, sc))
+	}
+	return p, nil
+}
+
+// parseVarsInclude returns files loaded by include_vars
+func (w *walker) parseVarsInclude(vi VarsInclude, sc scope) ([]string, error) {
+	if isTemplated(vi.File) || isTemplated(vi.Dir) {
+		return w.parseTemplatedVarsInclude(vi, sc)
+	}
+	if vi.File != "" {
+		p, err := w.findVarsPath(vi.File, sc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "findVarsPath file=%s", vi.File)
+		}
+		return []string{p}, nil
+	}
+	dir, err := w.findVarsPath(vi.Dir, sc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "findVarsPath dir=%s", vi.Dir)
+	}
+	return w.walkVarsInclude(dir, vi)
+}
+
+// parseTemplatedVarsInclude returns vars files which templated file or dir
+// may refer to, none found is not an error as it may be known at runtime only
+func (w *walker) parseTemplatedVarsInclude(vi VarsInclude, sc scope) ([]string, error) {
+	if vi.File != "" {
+		return w.findNeedles("vars", vi.File, sc, false)
+	}
+	dirs, err := w.findNeedles("vars", vi.Dir, sc, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "findNeedles dir=%s", vi.Dir)
+	}
+	files := []string{}
+	for _, dir := range dirs {
+		dFiles, dErr := w.walkVarsInclude(dir, vi)
+		if dErr != nil {
+			return nil, errors.Wrapf(dErr, "walkVarsInclude dir=%s", dir)
+		}
+		files = append(files, dFiles...)
+	}
+	return files, nil
+}
+
+// walkVarsInclude returns files of dir accepted by include_vars options
+func (w *walker) walkVarsInclude(dir string, vi VarsInclude) ([]string, error) {
+	var err error
+	var matcher *regexp.Regexp
+	if vi.FilesMatching != "" {
+		if matcher, err = regexp.Compile(vi.FilesMatching); err != nil {
+			return nil, errors.Wrapf(err, "regexp.Compile files_matching=%s", vi.FilesMatching)
+		}
+	}
+	extensions := vi.Extensions
+	if len(extensions) == 0 {
+		extensions = []string{"yml", "yaml", "json"}
+	}
+	accept := func(name string) bool {
+		if matcher != nil && !matcher.MatchString(name) {
+			return false
+		}
+		for _, ignored := range vi.IgnoreFiles {
+			if strings.HasSuffix(name, ignored) {
+				return false
+			}
+		}
+		ext := strings.TrimPrefix(path.Ext(name), ".")
+		for _, v := range extensions {
+			if ext == strings.TrimPrefix(v, ".") {
+				return true
+			}
+		}
+		return false
+	}
+	return w.walkDir(dir, 1, vi.Depth, accept)
+}
+
+// walkDir lists accepted files within dir, recursion is unlimited with
+// zero maxDepth
+func (w *walker) walkDir(dir string, depth int, maxDepth int, accept func(string) bool) ([]string, error) {
+	entries, err := w.ds.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ds.ReadDir dir_path=%s", dir)
+	}
+	sort.Strings(entries)
+	files := []string{}
+	for _, entry := range entries {
+		p := path.Join(dir, entry)
+		isDir, dErr := w.ds.IsDir(p)
+		if dErr != nil {
+			return nil, errors.Wrapf(dErr, "ds.IsDir path=%s", p)
+		}
+		if isDir {
+			if maxDepth != 0 && depth >= maxDepth {
+				continue
+			}
+			sub, sErr := w.walkDir(p, depth+1, maxDepth, accept)
+			if sErr != nil {
+				return nil, sErr
+			}
+			files = append(files, sub...)
+		} else if accept(entry) {
+			files = append(files, p)
+		}
+	}
+	return files, nil
+}
+
+// loadVars returns variables defined by existing files, later ones take
+// precedence. Content which is not plain mapping e.g. encrypted is skipped
+func (w *walker) loadVars(files []string) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for _, f := range files {
+		if exist, err := w.ds.IsExist(f); err != nil {
+			return nil, errors.Wrapf(err, "ds.IsExist path=%s", f)
+		} else if !exist {
+			continue
+		}
+		if isDir, err := w.ds.IsDir(f); err != nil {
+			return nil, errors.Wrapf(err, "ds.IsDir path=%s", f)
+		} else if isDir {
+			continue
+		}
+		content, err := w.ds.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "dataSource file_path=%s", f)
+		}
+		m := map[interface{}]interface{}{}
+		if w.checkVault(f, content) {
+			continue
+		}
+		if err = yaml.Unmarshal(content, &m); err != nil {
+			continue
+		}
+		vars = mergeVars(vars, toVars(m))
+	}
+	return vars, nil
+}
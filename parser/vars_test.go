@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestVarsIncludeUnmarshalYAML(t *testing.T) {
+	for _, c := range []struct {
+		caseName string
+		content  string
+		err      bool
+		want     VarsInclude
+	}{
+		{
+			caseName: "plain_file",
+			content:  `prod.yml`,
+			want:     VarsInclude{File: "prod.yml"},
+		},
+		{
+			caseName: "free_form",
+			content:  `file=prod.yml name=settings`,
+			want:     VarsInclude{File: "prod.yml"},
+		},
+		{
+			caseName: "free_form_dir",
+			content:  `dir=conf.d files_matching=^app depth=1 extensions=yml,yaml`,
+			want:     VarsInclude{Dir: "conf.d", FilesMatching: "^app", Depth: 1, Extensions: StringList{"yml", "yaml"}},
+		},
+		{
+			caseName: "free_form_malformed_depth",
+			content:  `dir=conf.d depth=abc`,
+			err:      true,
+		},
+		{
+			caseName: "mapping",
+			content:  `{dir: conf.d, ignore_files: [skip.yml]}`,
+			want:     VarsInclude{Dir: "conf.d", IgnoreFiles: StringList{"skip.yml"}},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			out := VarsInclude{}
+			err := yaml.Unmarshal([]byte(c.content), &out)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
+
+func TestParseVarsInclude(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		vi       VarsInclude
+		sc       scope
+		setup    func()
+		err      bool
+		want     []string
+	}{
+		{
+			caseName: "file_in_role_vars_dir",
+			vi:       VarsInclude{File: "prod.yml"},
+			sc:       scope{playbookRoot: "pb", rolePath: "pb/roles/app"},
+			setup: func() {
+				ds.SetFile("pb/roles/app/vars/prod.yml", []byte(""))
+				ds.SetFile("pb/vars/prod.yml", []byte(""))
+			},
+			want: []string{"pb/roles/app/vars/prod.yml"},
+		},
+		{
+			caseName: "file_in_playbook_dir",
+			vi:       VarsInclude{File: "env/prod.yml"},
+			sc:       scope{playbookRoot: "pb", rolePath: "pb/roles/app"},
+			setup: func() {
+				ds.SetFile("pb/env/prod.yml", []byte(""))
+			},
+			want: []string{"pb/env/prod.yml"},
+		},
+		{
+			caseName: "file_not_exist",
+			vi:       VarsInclude{File: "not-exist.yml"},
+			sc:       scope{playbookRoot: "pb"},
+			setup:    func() {},
+			err:      true,
+		},
+		{
+			caseName: "dir_with_default_filters",
+			vi:       VarsInclude{Dir: "conf.d"},
+			sc:       scope{playbookRoot: "pb", rolePath: "pb/roles/app"},
+			setup: func() {
+				ds.SetFile("pb/roles/app/vars/conf.d/b.yml", []byte(""))
+				ds.SetFile("pb/roles/app/vars/conf.d/a.json", []byte(""))
+				ds.SetFile("pb/roles/app/vars/conf.d/README.md", []byte(""))
+				ds.SetFile("pb/roles/app/vars/conf.d/sub/c.yaml", []byte(""))
+			},
+			want: []string{
+				"pb/roles/app/vars/conf.d/a.json",
+				"pb/roles/app/vars/conf.d/b.yml",
+				"pb/roles/app/vars/conf.d/sub/c.yaml",
+			},
+		},
+		{
+			caseName: "dir_with_files_matching_and_depth",
+			vi:       VarsInclude{Dir: "conf.d", FilesMatching: "^app_", Depth: 1, IgnoreFiles: StringList{"skip.yml"}},
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/conf.d/app_a.yml", []byte(""))
+				ds.SetFile("pb/conf.d/app_skip.yml", []byte(""))
+				ds.SetFile("pb/conf.d/db.yml", []byte(""))
+				ds.SetFile("pb/conf.d/sub/app_b.yml", []byte(""))
+			},
+			want: []string{"pb/conf.d/app_a.yml"},
+		},
+		{
+			caseName: "dir_with_malformed_files_matching",
+			vi:       VarsInclude{Dir: "conf.d", FilesMatching: "(["},
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/conf.d/app_a.yml", []byte(""))
+			},
+			err: true,
+		},
+		{
+			caseName: "templated_file_resolved",
+			vi:       VarsInclude{File: "{{ env }}.yml"},
+			sc:       scope{playbookRoot: "pb", rolePath: "pb/roles/app", vars: map[string]interface{}{"env": "prod"}},
+			setup: func() {
+				ds.SetFile("pb/roles/app/vars/prod.yml", []byte(""))
+				ds.SetFile("pb/roles/app/vars/dev.yml", []byte(""))
+			},
+			want: []string{"pb/roles/app/vars/prod.yml"},
+		},
+		{
+			caseName: "templated_file_glob",
+			vi:       VarsInclude{File: "os_{{ ansible_distribution }}.yml"},
+			sc:       scope{playbookRoot: "pb", rolePath: "pb/roles/app"},
+			setup: func() {
+				ds.SetFile("pb/roles/app/vars/os_debian.yml", []byte(""))
+				ds.SetFile("pb/roles/app/vars/os_redhat.yml", []byte(""))
+				ds.SetFile("pb/roles/app/vars/main.yml", []byte(""))
+			},
+			want: []string{"pb/roles/app/vars/os_debian.yml", "pb/roles/app/vars/os_redhat.yml"},
+		},
+		{
+			caseName: "templated_dir_glob",
+			vi:       VarsInclude{Dir: "{{ env }}"},
+			sc:       scope{playbookRoot: "pb"},
+			setup: func() {
+				ds.SetFile("pb/vars/dev/a.yml", []byte(""))
+				ds.SetFile("pb/vars/prod/b.yml", []byte(""))
+			},
+			want: []string{"pb/vars/dev/a.yml", "pb/vars/prod/b.yml"},
+		},
+		{
+			caseName: "templated_file_not_found",
+			vi:       VarsInclude{File: "{{ env }}.yml"},
+			sc:       scope{playbookRoot: "pb"},
+			setup:    func() {},
+			want:     nil,
+		},
+		{
+			caseName: "dir_not_exist",
+			vi:       VarsInclude{Dir: "not-exist"},
+			sc:       scope{playbookRoot: "pb"},
+			setup:    func() {},
+			err:      true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			w := &walker{cfg: &Config{}, ds: ds}
+			out, err := w.parseVarsInclude(c.vi, c.sc)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// vaultHeader starts payload encrypted by ansible-vault
+const vaultHeader = "$ANSIBLE_VAULT;"
+
+// isVaulted reports whether value is vault payload, `!vault` tagged values
+// are decoded as plain strings holding one
+func isVaulted(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), vaultHeader)
+}
+
+// hasVaultedValue reports whether any scalar of decoded yaml is vault payload
+func hasVaultedValue(v interface{}) bool {
+	switch val := v.(type) {
+	case string:
+		return isVaulted(val)
+	case []interface{}:
+		for _, item := range val {
+			if hasVaultedValue(item) {
+				return true
+			}
+		}
+	case map[interface{}]interface{}:
+		for _, item := range val {
+			if hasVaultedValue(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkVault records file holding vault payloads, either whole file or
+// inline values, reporting whether whole file is encrypted so its content
+// can't be parsed. Payloads are never decrypted
+func (w *walker) checkVault(file string, content []byte) bool {
+	if !bytes.Contains(content, []byte(vaultHeader)) {
+		return false
+	}
+	if isVaulted(string(content)) {
+		w.vaults = append(w.vaults, file)
+		return true
+	}
+	// header may just be mentioned within comments or text
+	var doc interface{}
+	if err := yaml.Unmarshal(content, &doc); err == nil && hasVaultedValue(doc) {
+		w.vaults = append(w.vaults, file)
+	}
+	return false
+}
+
+// usedVaults returns vaulted files read so far which are among deps, either
+// listed or within dir listed
+func (w *walker) usedVaults(deps []string) []string {
+	out := []string{}
+	for _, v := range uniq(w.vaults) {
+		for _, d := range deps {
+			if v == d || strings.HasPrefix(v, strings.TrimSuffix(d, "/")+"/") {
+				out = append(out, v)
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/meomap/zeno/loader"
+)
+
+const vaultPayload = `$ANSIBLE_VAULT;1.1;AES256
+62313365396662343061393464336163383764373764613633653634306231386433626436623361
+6134333665353966363534333632666535333761666131620a663537646436643839616531643561
+`
+
+func TestParsePlaybookVaults(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		playbook string
+		setup    func()
+		err      bool
+		want     []string
+		vaults   []string
+	}{
+		{
+			caseName: "vaulted_vars_files_and_include_vars",
+			playbook: "secrets.yml",
+			setup: func() {
+				ds.SetFile("secrets.yml", []byte(`
+- hosts: all
+  vars_files:
+  - vars/vault.yml
+  tasks:
+  - include_vars: secrets/db.yml`))
+				ds.SetFile("vars/vault.yml", []byte(vaultPayload))
+				ds.SetFile("secrets/db.yml", []byte(vaultPayload))
+			},
+			want:   []string{"secrets.yml", "vars/vault.yml", "secrets/db.yml"},
+			vaults: []string{"vars/vault.yml", "secrets/db.yml"},
+		},
+		{
+			caseName: "inline_vault_values",
+			playbook: "inline.yml",
+			setup: func() {
+				ds.SetFile("inline.yml", []byte(`
+- hosts: all
+  vars:
+    env: !vault |
+      $ANSIBLE_VAULT;1.1;AES256
+      62313365396662343061393464336163383764373764613633653634306231386433626436623361
+  roles:
+  - app`))
+				ds.SetFile("roles/app/defaults/main.yml", []byte(`
+app_password: !vault |
+  $ANSIBLE_VAULT;1.1;AES256
+  62313365396662343061393464336163383764373764613633653634306231386433626436623361`))
+				ds.SetFile("roles/app/tasks/main.yml", []byte(`
+- include_tasks: "env/{{ env }}.yml"`))
+				ds.SetFile("roles/app/tasks/env/prod.yml", []byte(""))
+			},
+			want: []string{
+				"inline.yml",
+				"roles/app/defaults/main.yml",
+				"roles/app/tasks/main.yml",
+				"roles/app/tasks/env/prod.yml",
+			},
+			vaults: []string{"inline.yml", "roles/app/defaults/main.yml"},
+		},
+		{
+			caseName: "vaulted_task_file",
+			playbook: "vaulted_tasks.yml",
+			setup: func() {
+				ds.SetFile("vaulted_tasks.yml", []byte(`
+- hosts: all
+  tasks:
+  - include_tasks: tasks/secret.yml`))
+				ds.SetFile("tasks/secret.yml", []byte(vaultPayload))
+			},
+			want:   []string{"vaulted_tasks.yml", "tasks/secret.yml"},
+			vaults: []string{"tasks/secret.yml"},
+		},
+		{
+			caseName: "vaulted_playbook",
+			playbook: "vaulted.yml",
+			setup: func() {
+				ds.SetFile("vaulted.yml", []byte(vaultPayload))
+			},
+			want:   []string{"vaulted.yml"},
+			vaults: []string{"vaulted.yml"},
+		},
+		{
+			caseName: "vaulted_handlers_never_notified",
+			playbook: "quiet.yml",
+			setup: func() {
+				ds.SetFile("quiet.yml", []byte(`
+- hosts: all
+  roles:
+  - app`))
+				ds.SetFile("roles/app/tasks/main.yml", []byte(`
+- command: /bin/deploy`))
+				ds.SetFile("roles/app/handlers/main.yml", []byte(`
+- name: restart app
+  command: /bin/restart
+  environment:
+    TOKEN: !vault |
+      $ANSIBLE_VAULT;1.1;AES256
+      62313365396662343061393464336163383764373764613633653634306231386433626436623361`))
+			},
+			want:   []string{"quiet.yml", "roles/app/tasks/main.yml"},
+			vaults: []string{},
+		},
+		{
+			caseName: "vault_header_mentioned_only",
+			playbook: "mention.yml",
+			setup: func() {
+				ds.SetFile("mention.yml", []byte(`
+# values start with $ANSIBLE_VAULT;1.1;AES256 once encrypted
+- hosts: all
+  vars:
+    hint: "encrypt with header $ANSIBLE_VAULT;1.1;AES256"`))
+			},
+			want:   []string{"mention.yml"},
+			vaults: []string{},
+		},
+		{
+			caseName: "playbook_without_vaults",
+			playbook: "plain.yml",
+			setup: func() {
+				ds.SetFile("plain.yml", []byte(`
+- hosts: all`))
+			},
+			want:   []string{"plain.yml"},
+			vaults: []string{},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			ds.Clear()
+			c.setup()
+			out, err := ParsePlaybook(c.playbook, "", nil, ds)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out.Files)
+				assert.Equal(t, c.vaults, out.Vaults)
+			}
+		})
+	}
+}
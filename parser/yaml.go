@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// StringList accepts either single scalar or list of scalars
+type StringList []string
+
+// UnmarshalYAML converts scalar values into list of string
+func (sl *StringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var items []interface{}
+	if err := unmarshal(&items); err != nil {
+		var item interface{}
+		if err = unmarshal(&item); err != nil {
+			return err
+		}
+		items = []interface{}{item}
+	}
+	out := StringList{}
+	for _, v := range items {
+		if v == nil {
+			continue
+		}
+		switch v.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return errors.Errorf("expected scalar value but got %v", v)
+		}
+		out = append(out, fmt.Sprint(v))
+	}
+	*sl = out
+	return nil
+}
+
+// scalarsOf returns scalar values of decoded scalar or list, others ignored
+func scalarsOf(v interface{}) []string {
+	items, ok := v.([]interface{})
+	if !ok {
+		items = []interface{}{v}
+	}
+	out := []string{}
+	for _, item := range items {
+		switch item.(type) {
+		case nil, map[interface{}]interface{}, []interface{}:
+			continue
+		}
+		out = append(out, fmt.Sprint(item))
+	}
+	return out
+}
+
+// keywords of tasks & plays which may be written as fully qualified names of
+// builtin modules
+var builtinKeywords = map[string]bool{
+	"include_tasks":   true,
+	"import_tasks":    true,
+	"include":         true,
+	"include_role":    true,
+	"import_role":     true,
+	"include_vars":    true,
+	"import_playbook": true,
+}
+
+// normalizeKeywords strips `ansible.builtin.` & `ansible.legacy.` prefixes
+// of builtin keywords, reporting whether any of fields was renamed
+func normalizeKeywords(fields map[string]interface{}) bool {
+	renamed := false
+	for k, v := range fields {
+		for _, prefix := range []string{"ansible.builtin.", "ansible.legacy."} {
+			short := strings.TrimPrefix(k, prefix)
+			if short == k || !builtinKeywords[short] {
+				continue
+			}
+			delete(fields, k)
+			fields[short] = v
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+// unmarshalNormalized decodes value into out with builtin keywords given by
+// fully qualified names renamed to short ones, fields holds decoded mapping
+func unmarshalNormalized(unmarshal func(interface{}) error, fields map[string]interface{}, out interface{}) error {
+	if !normalizeKeywords(fields) {
+		return unmarshal(out)
+	}
+	content, err := yaml.Marshal(fields)
+	if err != nil {
+		return errors.Wrap(err, "yaml.Marshal")
+	}
+	return yaml.Unmarshal(content, out)
+}
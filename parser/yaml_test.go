@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestStringListUnmarshalYAML(t *testing.T) {
+	for _, c := range []struct {
+		caseName string
+		content  string
+		err      bool
+		want     StringList
+	}{
+		{
+			caseName: "single_string",
+			content:  `web`,
+			want:     StringList{"web"},
+		},
+		{
+			caseName: "single_bool",
+			content:  `true`,
+			want:     StringList{"true"},
+		},
+		{
+			caseName: "list_of_scalars",
+			content:  `[web, 1, "db"]`,
+			want:     StringList{"web", "1", "db"},
+		},
+		{
+			caseName: "nested_mapping",
+			content:  `[{a: b}]`,
+			err:      true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			var out StringList
+			err := yaml.Unmarshal([]byte(c.content), &out)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
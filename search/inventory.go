@@ -0,0 +1,35 @@
+package search
+
+import (
+	"path"
+	"strings"
+)
+
+const (
+	groupVarsDir = "group_vars"
+	hostVarsDir  = "host_vars"
+)
+
+// inventoryVars tells whether file is group_vars/host_vars located in one of
+// base dirs, returning kind of vars dir with group or host name
+func inventoryVars(file string, baseDirs []string) (string, string, bool) {
+	for _, base := range baseDirs {
+		for _, kind := range []string{groupVarsDir, hostVarsDir} {
+			prefix := path.Join(base, kind) + "/"
+			if !strings.HasPrefix(file, prefix) {
+				continue
+			}
+			rel := strings.TrimPrefix(file, prefix)
+			// either vars file named by group/host or file within such dir
+			name := strings.SplitN(rel, "/", 2)[0]
+			if name == rel {
+				switch ext := path.Ext(name); ext {
+				case ".yml", ".yaml", ".json":
+					name = strings.TrimSuffix(name, ext)
+				}
+			}
+			return kind, name, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,29 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventoryVars(t *testing.T) {
+	baseDirs := []string{"inventories/prod", "playbooks"}
+	for k, c := range []struct {
+		file string
+		kind string
+		name string
+		ok   bool
+	}{
+		{file: "inventories/prod/group_vars/webservers.yml", kind: groupVarsDir, name: "webservers", ok: true},
+		{file: "inventories/prod/group_vars/webservers/vault.yml", kind: groupVarsDir, name: "webservers", ok: true},
+		{file: "playbooks/host_vars/web01.example.com", kind: hostVarsDir, name: "web01.example.com", ok: true},
+		{file: "playbooks/host_vars/db1.json", kind: hostVarsDir, name: "db1", ok: true},
+		{file: "playbooks/roles/app/vars/main.yml", ok: false},
+		{file: "other/group_vars/all.yml", ok: false},
+	} {
+		kind, name, ok := inventoryVars(c.file, baseDirs)
+		assert.Equal(t, c.ok, ok, "%d", k)
+		assert.Equal(t, c.kind, kind, "%d", k)
+		assert.Equal(t, c.name, name, "%d", k)
+	}
+}
@@ -1,22 +1,75 @@
 package search
 
 import (
+	"path"
+	"path/filepath"
+
 	"github.com/pkg/errors"
 
+	"github.com/meomap/zeno/inventory"
 	"github.com/meomap/zeno/loader"
 	"github.com/meomap/zeno/parser"
 )
 
-// MatchPlaybook reports whether pb appear in affected changes
-func MatchPlaybook(pb string, files []string, root string, ds loader.DataSource) (bool, error) {
-	deps, err := parser.ParsePlaybook(pb, root, ds)
+// MatchPlaybook reports whether pb appear in affected changes. With inventory
+// given, changes of group_vars & host_vars only match plays targeting those
+// hosts. With requirement changes given, playbooks using changed roles or
+// collections are matched too
+func MatchPlaybook(pb string, files []string, root string, cfg *parser.Config, inv *inventory.Inventory, reqs *RequirementChanges, ds loader.DataSource) (bool, error) {
+	result, err := parser.ParsePlaybook(pb, root, cfg, ds)
 	if err != nil {
 		return false, errors.Wrapf(err, "parser.ParsePlaybook pb=%s root=%s", pb, root)
 	}
-	for _, v := range deps {
-		if matchPath(v, files) {
-			return true, nil
+	return MatchParsed(pb, result, files, root, inv, reqs), nil
+}
+
+// MatchParsed reports whether pb appear in affected changes as MatchPlaybook
+// does, given dependencies already parsed
+func MatchParsed(pb string, result *parser.Playbook, files []string, root string, inv *inventory.Inventory, reqs *RequirementChanges) bool {
+	if reqs != nil && reqs.match(result) {
+		return true
+	}
+	others := files
+	if inv != nil {
+		var matched bool
+		baseDirs := []string{inv.Dir, filepath.Dir(path.Join(root, pb))}
+		if matched, others = matchInventoryVars(result.Hosts, files, baseDirs, inv); matched {
+			return true
+		}
+	}
+	for _, v := range result.Files {
+		if matchPath(v, others) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchInventoryVars reports whether any changed group/host vars applies to
+// targeted hosts, returning the rest of files which are not inventory vars
+func matchInventoryVars(patterns []string, files []string, baseDirs []string, inv *inventory.Inventory) (bool, []string) {
+	targets := map[string]bool{}
+	for _, p := range patterns {
+		for _, h := range inv.Hosts(p) {
+			targets[h] = true
+		}
+	}
+	others := []string{}
+	for _, f := range files {
+		kind, name, ok := inventoryVars(f, baseDirs)
+		if !ok {
+			others = append(others, f)
+			continue
+		}
+		hosts := []string{name}
+		if kind == groupVarsDir {
+			hosts = inv.GroupHosts(name)
+		}
+		for _, h := range hosts {
+			if targets[h] {
+				return true, nil
+			}
 		}
 	}
-	return false, nil
+	return false, others
 }
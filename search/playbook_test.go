@@ -7,7 +7,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/meomap/zeno/inventory"
 	"github.com/meomap/zeno/loader"
+	"github.com/meomap/zeno/parser"
 )
 
 func TestMatchPlaybook(t *testing.T) {
@@ -15,6 +17,8 @@ func TestMatchPlaybook(t *testing.T) {
 	for _, c := range []struct {
 		caseName string
 		playbook string
+		root     string
+		cfg      *parser.Config
 		diffs    []string
 		setup    func()
 		err      bool
@@ -51,6 +55,302 @@ func TestMatchPlaybook(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			caseName: "role_unused_task_changed",
+			playbook: "unused_task.yml",
+			diffs:    []string{"roles/db/tasks/restore.yml"},
+			setup: func() {
+				ds.SetFile("unused_task.yml", []byte(`
+- hosts: all
+  tasks:
+  - include_role:
+      name: db
+      tasks_from: backup`))
+				ds.SetFile("roles/db/tasks/backup.yml", []byte(""))
+				ds.SetFile("roles/db/tasks/restore.yml", []byte(""))
+			},
+			want: false,
+		},
+		{
+			caseName: "role_entry_point_changed",
+			playbook: "entry_point.yml",
+			diffs:    []string{"roles/db/tasks/backup.yml"},
+			setup: func() {
+				ds.SetFile("entry_point.yml", []byte(`
+- hosts: all
+  tasks:
+  - include_role:
+      name: db
+      tasks_from: backup`))
+				ds.SetFile("roles/db/tasks/backup.yml", []byte(""))
+				ds.SetFile("roles/db/tasks/restore.yml", []byte(""))
+			},
+			want: true,
+		},
+		{
+			caseName: "absolute_root_unused_files_changed",
+			playbook: "site.yml",
+			root:     "/repo",
+			diffs:    []string{"/repo/roles/db/tasks/restore.yml", "/repo/README.md"},
+			setup: func() {
+				ds.SetFile("site.yml", []byte(`
+- hosts: all
+  tasks:
+  - include_role:
+      name: db
+      tasks_from: backup`))
+				ds.SetFile("/repo/roles/db/tasks/backup.yml", []byte(""))
+				ds.SetFile("/repo/roles/db/tasks/restore.yml", []byte(""))
+				ds.SetFile("/repo/README.md", []byte(""))
+			},
+			want: false,
+		},
+		{
+			caseName: "absolute_root_entry_point_changed",
+			playbook: "site.yml",
+			root:     "/repo",
+			diffs:    []string{"/repo/roles/db/tasks/backup.yml"},
+			setup: func() {
+				ds.SetFile("site.yml", []byte(`
+- hosts: all
+  tasks:
+  - include_role:
+      name: db
+      tasks_from: backup`))
+				ds.SetFile("/repo/roles/db/tasks/backup.yml", []byte(""))
+				ds.SetFile("/repo/roles/db/tasks/restore.yml", []byte(""))
+			},
+			want: true,
+		},
+		{
+			caseName: "absolute_root_playbook_changed",
+			playbook: "site.yml",
+			root:     "/repo",
+			diffs:    []string{"/repo/site.yml"},
+			setup: func() {
+				ds.SetFile("site.yml", []byte(`
+- hosts: all`))
+			},
+			want: true,
+		},
+		{
+			caseName: "absolute_root_ansible_cfg_changed",
+			playbook: "site.yml",
+			root:     "/repo",
+			cfg:      &parser.Config{Path: "/repo/ansible.cfg"},
+			diffs:    []string{"/repo/ansible.cfg"},
+			setup: func() {
+				ds.SetFile("site.yml", []byte(`
+- hosts: all`))
+				ds.SetFile("/repo/ansible.cfg", []byte(`
+[defaults]
+roles_path = ./vendor-roles`))
+			},
+			want: true,
+		},
+		{
+			caseName: "absolute_root_plugin_next_to_playbook_changed",
+			playbook: "site.yml",
+			root:     "/repo",
+			diffs:    []string{"/repo/callback_plugins/timer.py"},
+			setup: func() {
+				ds.SetFile("site.yml", []byte(`
+- hosts: all`))
+				ds.SetFile("/repo/callback_plugins/timer.py", []byte(""))
+			},
+			want: true,
+		},
+		{
+			caseName: "absolute_root_globbed_include_next_to_playbook",
+			playbook: "site.yml",
+			root:     "/repo",
+			diffs:    []string{"/repo/debian.yml"},
+			setup: func() {
+				content := []byte(`
+- hosts: all
+  tasks:
+  - include_tasks: "{{ os }}.yml"`)
+				ds.SetFile("site.yml", content)
+				ds.SetFile("/repo/site.yml", content)
+				ds.SetFile("/repo/debian.yml", []byte(`
+- debug: msg=debian`))
+				ds.SetFile("/repo/docker-compose.yml", []byte(`
+services: {}`))
+			},
+			want: true,
+		},
+		{
+			caseName: "custom_module_changed",
+			playbook: "dns.yml",
+			diffs:    []string{"roles/dns/library/our_dns.py"},
+			setup: func() {
+				ds.SetFile("dns.yml", []byte(`
+- hosts: all
+  roles:
+  - dns`))
+				ds.SetFile("roles/dns/tasks/main.yml", []byte(`
+- our_dns:
+    name: "{{ inventory_hostname }}"`))
+				ds.SetFile("roles/dns/library/our_dns.py", []byte(""))
+				ds.SetFile("roles/dns/library/our_cdn.py", []byte(""))
+			},
+			want: true,
+		},
+		{
+			caseName: "unused_custom_module_changed",
+			playbook: "dns.yml",
+			diffs:    []string{"roles/dns/library/our_cdn.py"},
+			setup: func() {
+				ds.SetFile("dns.yml", []byte(`
+- hosts: all
+  roles:
+  - dns`))
+				ds.SetFile("roles/dns/tasks/main.yml", []byte(`
+- our_dns:
+    name: "{{ inventory_hostname }}"`))
+				ds.SetFile("roles/dns/library/our_dns.py", []byte(""))
+				ds.SetFile("roles/dns/library/our_cdn.py", []byte(""))
+			},
+			want: false,
+		},
+		{
+			caseName: "module_utils_of_custom_module_changed",
+			playbook: "dns.yml",
+			diffs:    []string{"module_utils/our_common.py"},
+			setup: func() {
+				ds.SetFile("dns.yml", []byte(`
+- hosts: all
+  roles:
+  - dns`))
+				ds.SetFile("roles/dns/tasks/main.yml", []byte(`
+- our_dns:
+    name: "{{ inventory_hostname }}"`))
+				ds.SetFile("roles/dns/library/our_dns.py", []byte(`
+from ansible.module_utils.our_common import retry`))
+				ds.SetFile("module_utils/our_common.py", []byte(""))
+			},
+			want: true,
+		},
+		{
+			caseName: "used_template_changed",
+			playbook: "web.yml",
+			diffs:    []string{"roles/web/templates/nginx.conf.j2"},
+			setup: func() {
+				ds.SetFile("web.yml", []byte(`
+- hosts: all
+  roles:
+  - web`))
+				ds.SetFile("roles/web/tasks/main.yml", []byte(`
+- template: src=nginx.conf.j2 dest=/etc/nginx/nginx.conf`))
+				ds.SetFile("roles/web/templates/nginx.conf.j2", []byte(""))
+				ds.SetFile("roles/web/templates/apache.conf.j2", []byte(""))
+			},
+			want: true,
+		},
+		{
+			caseName: "unused_template_changed",
+			playbook: "web.yml",
+			diffs:    []string{"roles/web/templates/apache.conf.j2"},
+			setup: func() {
+				ds.SetFile("web.yml", []byte(`
+- hosts: all
+  roles:
+  - web`))
+				ds.SetFile("roles/web/tasks/main.yml", []byte(`
+- template: src=nginx.conf.j2 dest=/etc/nginx/nginx.conf`))
+				ds.SetFile("roles/web/templates/nginx.conf.j2", []byte(""))
+				ds.SetFile("roles/web/templates/apache.conf.j2", []byte(""))
+			},
+			want: false,
+		},
+		{
+			caseName: "shared_template_partial_changed",
+			playbook: "web.yml",
+			diffs:    []string{"roles/common/templates/ssl.j2"},
+			setup: func() {
+				ds.SetFile("web.yml", []byte(`
+- hosts: all
+  roles:
+  - web`))
+				ds.SetFile("roles/web/tasks/main.yml", []byte(`
+- template: src=nginx.conf.j2 dest=/etc/nginx/nginx.conf`))
+				ds.SetFile("roles/web/templates/nginx.conf.j2", []byte(`
+{% include 'roles/common/templates/ssl.j2' %}`))
+				ds.SetFile("roles/common/templates/ssl.j2", []byte(""))
+			},
+			want: true,
+		},
+		{
+			caseName: "file_lookup_of_role_defaults_changed",
+			playbook: "deploy.yml",
+			diffs:    []string{"roles/deploy/files/keys/deploy.pub"},
+			setup: func() {
+				ds.SetFile("deploy.yml", []byte(`
+- hosts: all
+  roles:
+  - deploy`))
+				ds.SetFile("roles/deploy/defaults/main.yml", []byte(`
+deploy_key: "{{ lookup('file', 'keys/deploy.pub') }}"`))
+				ds.SetFile("roles/deploy/files/keys/deploy.pub", []byte(""))
+			},
+			want: true,
+		},
+		{
+			caseName: "custom_filter_changed",
+			playbook: "filter.yml",
+			diffs:    []string{"roles/dns/filter_plugins/net.py"},
+			setup: func() {
+				ds.SetFile("filter.yml", []byte(`
+- hosts: all
+  roles:
+  - dns`))
+				ds.SetFile("roles/dns/tasks/main.yml", []byte(`
+- debug:
+    msg: "{{ inventory_hostname | to_fqdn }}"`))
+				ds.SetFile("roles/dns/filter_plugins/net.py", []byte(`
+class FilterModule(object):
+    def filters(self):
+        return {'to_fqdn': to_fqdn}`))
+			},
+			want: true,
+		},
+		{
+			caseName: "notified_handler_of_other_role_changed",
+			playbook: "notify.yml",
+			diffs:    []string{"roles/common/handlers/main.yml"},
+			setup: func() {
+				ds.SetFile("notify.yml", []byte(`
+- hosts: all
+  roles:
+  - common
+  - app`))
+				ds.SetFile("roles/common/handlers/main.yml", []byte(`
+- name: restart nginx
+  service: name=nginx state=restarted`))
+				ds.SetFile("roles/app/tasks/main.yml", []byte(`
+- template: src=site.conf dest=/etc/nginx/conf.d/site.conf
+  notify: restart nginx`))
+			},
+			want: true,
+		},
+		{
+			caseName: "handler_not_notified_changed",
+			playbook: "silent.yml",
+			diffs:    []string{"roles/common/handlers/main.yml"},
+			setup: func() {
+				ds.SetFile("silent.yml", []byte(`
+- hosts: all
+  roles:
+  - common
+  - app`))
+				ds.SetFile("roles/common/handlers/main.yml", []byte(`
+- name: restart nginx
+  service: name=nginx state=restarted`))
+				ds.SetFile("roles/app/tasks/main.yml", []byte(`
+- command: /bin/true`))
+			},
+			want: false,
+		},
 		{
 			caseName: "playbook_error",
 			playbook: "error.yml",
@@ -70,7 +370,11 @@ func TestMatchPlaybook(t *testing.T) {
 		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
 			ds.Clear()
 			c.setup()
-			out, err := MatchPlaybook(c.playbook, c.diffs, ".", ds)
+			root := c.root
+			if root == "" {
+				root = "."
+			}
+			out, err := MatchPlaybook(c.playbook, c.diffs, root, c.cfg, nil, nil, ds)
 			if c.err == true {
 				assert.Error(t, err)
 			} else {
@@ -80,3 +384,98 @@ func TestMatchPlaybook(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchPlaybookWithInventory(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	ds.SetFile("inventories/prod/hosts", []byte(`
+[webservers]
+web01
+[dbservers]
+db01
+[prod:children]
+webservers
+dbservers`))
+	ds.SetFile("playbooks/web.yml", []byte(`
+- hosts: webservers`))
+	ds.SetFile("playbooks/db.yml", []byte(`
+- hosts: dbservers`))
+	ds.SetFile("playbooks/group_vars/webservers.yml", []byte(""))
+	inv, err := inventory.Parse("inventories/prod/hosts", ds)
+	require.NoError(t, err)
+	for _, c := range []struct {
+		caseName string
+		playbook string
+		diffs    []string
+		inv      *inventory.Inventory
+		want     bool
+	}{
+		{
+			caseName: "group_vars_of_targeted_group",
+			playbook: "playbooks/web.yml",
+			diffs:    []string{"inventories/prod/group_vars/webservers.yml"},
+			inv:      inv,
+			want:     true,
+		},
+		{
+			caseName: "group_vars_of_other_group",
+			playbook: "playbooks/db.yml",
+			diffs:    []string{"playbooks/group_vars/webservers.yml"},
+			inv:      inv,
+			want:     false,
+		},
+		{
+			caseName: "group_vars_of_parent_group",
+			playbook: "playbooks/db.yml",
+			diffs:    []string{"inventories/prod/group_vars/prod/main.yml"},
+			inv:      inv,
+			want:     true,
+		},
+		{
+			caseName: "group_vars_of_all",
+			playbook: "playbooks/db.yml",
+			diffs:    []string{"playbooks/group_vars/all.yml"},
+			inv:      inv,
+			want:     true,
+		},
+		{
+			caseName: "host_vars_of_targeted_host",
+			playbook: "playbooks/db.yml",
+			diffs:    []string{"inventories/prod/host_vars/db01.yml"},
+			inv:      inv,
+			want:     true,
+		},
+		{
+			caseName: "host_vars_of_other_host",
+			playbook: "playbooks/web.yml",
+			diffs:    []string{"inventories/prod/host_vars/db01.yml"},
+			inv:      inv,
+			want:     false,
+		},
+		{
+			caseName: "unused_file_next_to_playbook",
+			playbook: "playbooks/web.yml",
+			diffs:    []string{"playbooks/group_vars/dbservers.yml", "playbooks/files/app.conf"},
+			inv:      inv,
+			want:     false,
+		},
+		{
+			caseName: "playbook_next_to_group_vars_changed",
+			playbook: "playbooks/web.yml",
+			diffs:    []string{"playbooks/group_vars/dbservers.yml", "playbooks/web.yml"},
+			inv:      inv,
+			want:     true,
+		},
+		{
+			caseName: "group_vars_next_to_playbook_without_inventory",
+			playbook: "playbooks/db.yml",
+			diffs:    []string{"playbooks/group_vars/webservers.yml"},
+			want:     true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			out, err := MatchPlaybook(c.playbook, c.diffs, ".", nil, c.inv, nil, ds)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, out)
+		})
+	}
+}
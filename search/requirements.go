@@ -0,0 +1,85 @@
+package search
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/meomap/zeno/loader"
+	"github.com/meomap/zeno/parser"
+)
+
+// RequirementChanges lists roles & collections whose entries of galaxy
+// requirements files changed
+type RequirementChanges struct {
+	Roles       []string
+	Collections []string
+}
+
+// isRequirementsFile reports whether file is galaxy requirements file,
+// typically `roles/requirements.yml` or `collections/requirements.yml`
+func isRequirementsFile(file string) bool {
+	base := path.Base(file)
+	return base == "requirements.yml" || base == "requirements.yaml"
+}
+
+// loadRequirements returns parsed requirements file, nil if not exist
+func loadRequirements(file string, ds loader.DataSource) (*parser.Requirements, error) {
+	if exist, err := ds.IsExist(file); err != nil {
+		return nil, errors.Wrapf(err, "ds.IsExist path=%s", file)
+	} else if !exist {
+		return nil, nil
+	}
+	content, err := ds.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ds.ReadFile path=%s", file)
+	}
+	reqs, err := parser.ParseRequirements(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parser.ParseRequirements path=%s", file)
+	}
+	return reqs, nil
+}
+
+// DiffRequirements compares changed requirements files between before &
+// after data sources
+func DiffRequirements(files []string, before loader.DataSource, after loader.DataSource) (*RequirementChanges, error) {
+	changes := &RequirementChanges{}
+	for _, f := range files {
+		if !isRequirementsFile(f) {
+			continue
+		}
+		old, err := loadRequirements(f, before)
+		if err != nil {
+			return nil, errors.Wrap(err, "before")
+		}
+		cur, err := loadRequirements(f, after)
+		if err != nil {
+			return nil, errors.Wrap(err, "after")
+		}
+		roles, collections := parser.DiffRequirements(old, cur)
+		changes.Roles = append(changes.Roles, roles...)
+		changes.Collections = append(changes.Collections, collections...)
+	}
+	return changes, nil
+}
+
+// match reports whether playbook uses any changed role or collection
+func (rc *RequirementChanges) match(pb *parser.Playbook) bool {
+	for _, changed := range []struct {
+		names []string
+		used  []string
+	}{
+		{names: rc.Roles, used: pb.Roles},
+		{names: rc.Collections, used: pb.Collections},
+	} {
+		for _, n := range changed.names {
+			for _, u := range changed.used {
+				if n == u {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/meomap/zeno/loader"
+)
+
+func TestDiffRequirements(t *testing.T) {
+	before := new(loader.MemoryLoader)
+	after := new(loader.MemoryLoader)
+	for _, c := range []struct {
+		caseName string
+		files    []string
+		setup    func()
+		err      bool
+		want     *RequirementChanges
+	}{
+		{
+			caseName: "version_bumped",
+			files:    []string{"roles/requirements.yml", "roles/app/tasks/main.yml"},
+			setup: func() {
+				before.SetFile("roles/requirements.yml", []byte(`
+- src: geerlingguy.java
+  version: 1.9.0
+- src: geerlingguy.nginx`))
+				after.SetFile("roles/requirements.yml", []byte(`
+- src: geerlingguy.java
+  version: 1.10.0
+- src: geerlingguy.nginx`))
+			},
+			want: &RequirementChanges{Roles: []string{"geerlingguy.java"}},
+		},
+		{
+			caseName: "collections_file_added",
+			files:    []string{"collections/requirements.yml"},
+			setup: func() {
+				after.SetFile("collections/requirements.yml", []byte(`
+collections:
+- community.general`))
+			},
+			want: &RequirementChanges{Collections: []string{"community.general"}},
+		},
+		{
+			caseName: "requirements_file_removed",
+			files:    []string{"requirements.yaml"},
+			setup: func() {
+				before.SetFile("requirements.yaml", []byte(`
+roles:
+- name: app
+  src: https://git.example.com/app.git`))
+			},
+			want: &RequirementChanges{Roles: []string{"app"}},
+		},
+		{
+			caseName: "no_requirements_changed",
+			files:    []string{"site.yml"},
+			setup:    func() {},
+			want:     &RequirementChanges{},
+		},
+		{
+			caseName: "invalid_content",
+			files:    []string{"roles/requirements.yml"},
+			setup: func() {
+				after.SetFile("roles/requirements.yml", []byte("roles: abc"))
+			},
+			err: true,
+		},
+		{
+			caseName: "unexpected_error",
+			files:    []string{"roles/requirements.yml"},
+			setup: func() {
+				before.SetFile("roles/requirements.yml", []byte("unexpected_error"))
+			},
+			err: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			before.Clear()
+			after.Clear()
+			c.setup()
+			out, err := DiffRequirements(c.files, before, after)
+			if c.err == true {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.want, out)
+			}
+		})
+	}
+}
+
+func TestMatchPlaybookWithRequirements(t *testing.T) {
+	ds := new(loader.MemoryLoader)
+	ds.SetFile("java.yml", []byte(`
+- hosts: all
+  roles:
+  - app`))
+	ds.SetFile("roles/app/meta/main.yml", []byte(`
+dependencies: [geerlingguy.java]`))
+	ds.SetFile("roles/geerlingguy.java/tasks/main.yml", []byte(""))
+	ds.SetFile("mysql.yml", []byte(`
+- hosts: all
+  tasks:
+  - include_role:
+      name: community.mysql.server`))
+	ds.SetFile("collections/ansible_collections/community/mysql/roles/server/tasks/main.yml", []byte(""))
+	// galaxy content not committed is only listed in requirements
+	ds.SetFile("galaxy.yml", []byte(`
+- hosts: all
+  roles:
+  - geerlingguy.mysql
+  tasks:
+  - include_role:
+      name: community.postgresql.database`))
+	ds.SetFile("roles/requirements.yml", []byte(`
+roles:
+- name: geerlingguy.mysql
+  version: 4.3.0
+collections:
+- name: community.postgresql`))
+	for _, c := range []struct {
+		caseName string
+		playbook string
+		reqs     *RequirementChanges
+		want     bool
+	}{
+		{
+			caseName: "role_dependency_changed",
+			playbook: "java.yml",
+			reqs:     &RequirementChanges{Roles: []string{"geerlingguy.java"}},
+			want:     true,
+		},
+		{
+			caseName: "collection_changed",
+			playbook: "mysql.yml",
+			reqs:     &RequirementChanges{Collections: []string{"community.mysql"}},
+			want:     true,
+		},
+		{
+			caseName: "unused_role_changed",
+			playbook: "mysql.yml",
+			reqs:     &RequirementChanges{Roles: []string{"geerlingguy.java"}},
+			want:     false,
+		},
+		{
+			caseName: "role_not_installed_changed",
+			playbook: "galaxy.yml",
+			reqs:     &RequirementChanges{Roles: []string{"geerlingguy.mysql"}},
+			want:     true,
+		},
+		{
+			caseName: "collection_not_installed_changed",
+			playbook: "galaxy.yml",
+			reqs:     &RequirementChanges{Collections: []string{"community.postgresql"}},
+			want:     true,
+		},
+		{
+			caseName: "no_requirement_changes",
+			playbook: "java.yml",
+			want:     false,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			out, err := MatchPlaybook(c.playbook, []string{"roles/requirements.yml"}, ".", nil, nil, c.reqs, ds)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, out)
+		})
+	}
+}
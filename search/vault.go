@@ -0,0 +1,20 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/meomap/zeno/parser"
+)
+
+// VaultFiles returns files holding vault payloads which parsed playbook
+// depends on, relative to root
+func VaultFiles(result *parser.Playbook, root string) []string {
+	files := []string{}
+	for _, v := range result.Vaults {
+		if root != "" {
+			v = strings.TrimPrefix(v, strings.TrimSuffix(root, "/")+"/")
+		}
+		files = append(files, v)
+	}
+	return files
+}
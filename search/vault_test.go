@@ -0,0 +1,41 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/meomap/zeno/parser"
+)
+
+func TestVaultFiles(t *testing.T) {
+	for _, c := range []struct {
+		caseName string
+		vaults   []string
+		root     string
+		want     []string
+	}{
+		{
+			caseName: "playbook_without_vaults",
+			vaults:   []string{},
+			want:     []string{},
+		},
+		{
+			caseName: "vaults_of_relative_root",
+			vaults:   []string{"vars/secrets.yml", "roles/db/vars/main.yml"},
+			want:     []string{"vars/secrets.yml", "roles/db/vars/main.yml"},
+		},
+		{
+			caseName: "vaults_relative_to_absolute_root",
+			vaults:   []string{"/repo/vars/secrets.yml", "/etc/ansible/roles/db/vars/main.yml"},
+			root:     "/repo/",
+			want:     []string{"vars/secrets.yml", "/etc/ansible/roles/db/vars/main.yml"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%s", c.caseName), func(t *testing.T) {
+			out := VaultFiles(&parser.Playbook{Vaults: c.vaults}, c.root)
+			assert.Equal(t, c.want, out)
+		})
+	}
+}